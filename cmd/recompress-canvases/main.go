@@ -0,0 +1,60 @@
+// Simplifies the freehand paths already stored in canvases and reports the bytes saved.
+//
+//	go run ./cmd/recompress-canvases -tolerance 0.5 -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"qolboard-api/config"
+	database_config "qolboard-api/config/database"
+	"qolboard-api/services/logging"
+	maintenance_service "qolboard-api/services/maintenance"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	os.Setenv("TZ", "UTC")
+
+	err := godotenv.Load()
+	if err != nil {
+		logging.LogError("recompress-canvases", "Error loading .env file", err.Error())
+	}
+
+	tolerance := flag.Float64("tolerance", config.CanvasPathTolerance(), "how far simplified paths may stray from what was drawn, in canvas units, defaults to CANVAS_PATH_TOLERANCE")
+	dryRun := flag.Bool("dry-run", false, "report what would be saved without writing anything")
+	flag.Parse()
+
+	if !(*tolerance > 0) {
+		fmt.Fprintln(os.Stderr, "A tolerance greater than 0 is required, pass -tolerance or set CANVAS_PATH_TOLERANCE")
+		os.Exit(2)
+	}
+
+	database_config.ConnectToDatabase()
+
+	// Batches already committed stay recompressed when interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := maintenance_service.RecompressCanvases(ctx, *tolerance, *dryRun)
+
+	verb := "Recompressed"
+	if *dryRun {
+		verb = "Would recompress"
+	}
+	fmt.Printf("%s %d of %d canvases (%d pieces), %d bytes saved of %d\n",
+		verb, report.Recompressed, report.Canvases, report.Pieces, report.BytesSaved(), report.BytesBefore)
+	if report.Skipped > 0 {
+		fmt.Printf("Skipped %d canvases which looked open in a live room, run it again once they are quiet\n", report.Skipped)
+	}
+
+	if err != nil {
+		logging.LogError("recompress-canvases", "Error recompressing canvases", err)
+		os.Exit(1)
+	}
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"math"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -9,6 +11,103 @@ func IsDev() bool {
 	return os.Getenv("ENV") == "dev"
 }
 
+// "postgres" to fan websocket rooms out across API instances, anything else keeps them in memory
+func WebsocketBackend() string {
+	return os.Getenv("WS_BACKEND")
+}
+
+func WebsocketBackendChannel() string {
+	return "qolboard_ws"
+}
+
+// Most pieces a single canvas can hold
+func CanvasMaxPieces() int {
+	return 20000
+}
+
+// Largest canvas payload accepted, both when saving a canvas and for a single websocket message
+func CanvasMaxBytes() int64 {
+	return 20 << 20
+}
+
+// How far in canvas units simplified freehand paths may stray from what was drawn, CANVAS_PATH_TOLERANCE turns
+// simplification on for new pieces and saves. Unset or 0 keeps paths as drawn
+func CanvasPathTolerance() float64 {
+	tolerance, err := strconv.ParseFloat(os.Getenv("CANVAS_PATH_TOLERANCE"), 64)
+	if err != nil || !(tolerance > 0) || math.IsInf(tolerance, 1) {
+		return 0
+	}
+	return tolerance
+}
+
+// Longest side of canvas thumbnails in pixels
+func CanvasThumbnailSize() int {
+	return 256
+}
+
+// Largest SVG file accepted when importing a canvas
+func CanvasImportMaxBytes() int64 {
+	return 5 << 20
+}
+
+// Directory uploaded assets are stored in when using local storage, ASSET_STORAGE_PATH overrides it
+func AssetStoragePath() string {
+	path := os.Getenv("ASSET_STORAGE_PATH")
+	if path == "" {
+		return "storage/assets"
+	}
+	return path
+}
+
+// Largest file accepted when uploading an asset
+func AssetMaxBytes() int64 {
+	return 10 << 20
+}
+
+// Content types accepted for uploaded assets, sniffed from the file. SVG is left out as it can carry scripts
+func AssetContentTypes() []string {
+	return []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+}
+
+// Longest side of exported canvas images in pixels, the export scale is lowered to fit
+func CanvasExportMaxSize() int {
+	return 4096
+}
+
+// Number of recent messages each websocket room keeps for replaying to reconnecting clients
+func WebsocketReplayBufferSize() int {
+	return 200
+}
+
+// Number of piece changes each user can undo within a websocket room
+func WebsocketUndoHistorySize() int {
+	return 100
+}
+
+// Minimum time between relayed cursor events from a single websocket connection
+func WebsocketCursorInterval() time.Duration {
+	return 50 * time.Millisecond
+}
+
+// Minimum time between relayed viewport events from a single websocket connection
+func WebsocketViewportInterval() time.Duration {
+	return 200 * time.Millisecond
+}
+
+// How long deleted canvases stay in the trash before being purged, CANVAS_TRASH_RETENTION takes a duration such as "720h"
+func CanvasTrashRetention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("CANVAS_TRASH_RETENTION"))
+	if err != nil || retention <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return retention
+}
+
+// Time between background purges of expired canvases in the trash
+func CanvasTrashPurgeInterval() time.Duration {
+	return time.Hour
+}
+
 func TTLJWTToken() time.Duration {
 	return 15 * time.Minute
 }
@@ -32,3 +131,13 @@ func RateLimitRegister() time.Duration {
 func RateLimitRequestOTP() time.Duration {
 	return 15 * time.Minute
 }
+
+// Number of canvas versions kept per canvas, older versions are pruned
+func CanvasVersionsRetainCount() int {
+	return 50
+}
+
+// Minimum time between version snapshots written by websocket room saves
+func CanvasVersionsWebsocketInterval() time.Duration {
+	return 5 * time.Minute
+}
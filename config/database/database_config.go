@@ -50,16 +50,14 @@ func beginDbTransaction(c *gin.Context) (*sqlx.Tx, error) {
 	return tx, err
 }
 
-func ConnectToDatabase() {
-	var err error = nil
-
+func DSN() string {
 	host := os.Getenv("DB_HOST")
 	name := os.Getenv("DB_NAME")
 	username := os.Getenv("DB_USERNAME")
 	password := os.Getenv("DB_PASSWORD")
 	sslmode := os.Getenv("DB_SSLMODE")
 
-	dsn := fmt.Sprintf(
+	return fmt.Sprintf(
 		"postgresql://%s:%s@%s/%s?sslmode=%s",
 		username,
 		password,
@@ -67,8 +65,12 @@ func ConnectToDatabase() {
 		name,
 		sslmode,
 	)
+}
+
+func ConnectToDatabase() {
+	var err error = nil
 
-	db, err = sqlx.Open("pgx", dsn)
+	db, err = sqlx.Open("pgx", DSN())
 	if err != nil {
 		logging.LogError("ConnectToDatabase", "Error connecting to database", err.Error())
 		panic(1)
@@ -0,0 +1,165 @@
+package asset_controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"qolboard-api/config"
+	database_config "qolboard-api/config/database"
+	model "qolboard-api/models"
+	asset_model "qolboard-api/models/asset"
+	service "qolboard-api/services"
+	"qolboard-api/services/database"
+	error_service "qolboard-api/services/error"
+	"qolboard-api/services/logging"
+	response_service "qolboard-api/services/response"
+	storage_service "qolboard-api/services/storage"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssetHandler struct {
+	storage storage_service.Storage
+}
+
+func NewAssetHandler(storage storage_service.Storage) *AssetHandler {
+	return &AssetHandler{
+		storage: storage,
+	}
+}
+
+// Uploads a file for a canvas the user can edit, the content type is sniffed from the file rather than trusted from the client
+func (h *AssetHandler) Upload(c *gin.Context) {
+	canvasId := c.Param("canvas_id")
+	maxBytes := config.AssetMaxBytes()
+
+	// Leave room for the rest of the multipart body
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+(1<<20))
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			error_service.PublicError(c, fmt.Sprintf("File must be at most %d bytes", maxBytes), http.StatusRequestEntityTooLarge, "file", "", "asset")
+			return
+		}
+		error_service.PublicError(c, "A file is required", http.StatusUnprocessableEntity, "file", "", "asset")
+		return
+	}
+	if fileHeader.Size > maxBytes {
+		error_service.PublicError(c, fmt.Sprintf("File must be at most %d bytes", maxBytes), http.StatusRequestEntityTooLarge, "file", fileHeader.Filename, "asset")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer file.Close()
+
+	// http.DetectContentType looks at no more than the first 512 bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !slices.Contains(config.AssetContentTypes(), contentType) {
+		error_service.PublicError(c, fmt.Sprintf("Unsupported file type %s", contentType), http.StatusUnsupportedMediaType, "file", fileHeader.Filename, "asset")
+		return
+	}
+
+	code, err := service.GenerateCode(32)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	asset := &model.Asset{
+		CanvasId:    &canvasId,
+		StorageKey:  "assets/" + code,
+		ContentType: contentType,
+		Size:        fileHeader.Size,
+		Filename:    filepath.Base(fileHeader.Filename),
+	}
+
+	err = asset.Insert(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", canvasId, "canvas")
+		return
+	}
+
+	// Stored before committing, so a failed upload never leaves a row pointing at nothing
+	err = h.storage.Put(c.Request.Context(), asset.StorageKey, io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if deleteErr := h.storage.Delete(c.Request.Context(), asset.StorageKey); deleteErr != nil {
+			logging.LogError("asset_controller", "Error deleting stored file of uncommitted asset", deleteErr)
+		}
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"msg":   fmt.Sprintf("Successfully uploaded %q to canvas %v", asset.Filename, canvasId),
+		"asset": asset,
+	})
+}
+
+// Serves an asset's file to users with access to its canvas
+func (h *AssetHandler) Download(c *gin.Context) {
+	canvasId := c.Param("canvas_id")
+	assetId := c.Param("asset_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	asset, err := asset_model.Get(tx, canvasId, assetId)
+	if err != nil {
+		error_service.PublicError(c, "Could not find asset", http.StatusNotFound, "asset_id", assetId, "asset")
+		return
+	}
+	tx.Commit()
+
+	file, err := h.storage.Get(c.Request.Context(), asset.StorageKey)
+	if errors.Is(err, storage_service.ErrNotFound) {
+		error_service.PublicError(c, "Could not find asset", http.StatusNotFound, "asset_id", assetId, "asset")
+		return
+	}
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer file.Close()
+
+	// Stored files never change, but they must not end up in shared caches
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, asset.Size, asset.ContentType, file, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("inline", map[string]string{"filename": asset.Filename}),
+	})
+}
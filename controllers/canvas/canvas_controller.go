@@ -1,38 +1,104 @@
 package canvas_controller
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
+	"qolboard-api/config"
 	database_config "qolboard-api/config/database"
 	"qolboard-api/controllers"
 	model "qolboard-api/models"
 	canvas_model "qolboard-api/models/canvas"
-	service "qolboard-api/services"
+	canvas_shared_access_model "qolboard-api/models/canvas_shared_access"
+	canvas_template_model "qolboard-api/models/canvas_template"
 	auth_service "qolboard-api/services/auth"
 	canvas_service "qolboard-api/services/canvas"
 	error_service "qolboard-api/services/error"
+	excalidraw_service "qolboard-api/services/excalidraw"
 	"qolboard-api/services/logging"
 	relations_service "qolboard-api/services/relations"
 	response_service "qolboard-api/services/response"
 	websocket_service "qolboard-api/services/websocket"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type getParams struct {
 	controllers.GetParams
 }
 
+type websocketParams struct {
+	getParams
+	Epoch   string  `form:"epoch" binding:"required_with=LastSeq"`
+	LastSeq *uint64 `form:"last_seq"`
+	// Join without the pieces, for clients which load big canvases a viewport at a time with query-viewport
+	WithoutPieces bool `form:"without_pieces"`
+}
+
+type exportParams struct {
+	Crop    bool    `form:"crop"`
+	Padding float64 `form:"padding" binding:"gte=0,lte=1000"`
+}
+
+type exportPNGParams struct {
+	exportParams
+	Scale float64 `form:"scale" binding:"omitempty,gt=0,lte=8"`
+}
+
+type piecesParams struct {
+	Left   *float64 `form:"left" binding:"required"`
+	Top    *float64 `form:"top" binding:"required"`
+	Right  *float64 `form:"right" binding:"required"`
+	Bottom *float64 `form:"bottom" binding:"required"`
+}
+
+type moveToFolderBody struct {
+	FolderId *string `json:"folder_id" binding:"omitempty,uuid"` // null moves the canvas out of its folder
+}
+
+type duplicateBody struct {
+	CopySharing bool `json:"copy_sharing"` // Only the owner may copy who the canvas is shared with
+}
+
 type indexParams struct {
 	controllers.IndexParams
 }
 
+type searchParams struct {
+	indexParams
+	Search        string     `form:"search" binding:"max=255"`
+	Ownership     string     `form:"ownership" binding:"omitempty,oneof=owned shared"`
+	UpdatedAfter  *time.Time `form:"updated_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedBefore *time.Time `form:"updated_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	FolderId      string     `form:"folder_id" binding:"omitempty,uuid|eq=none"` // "none" for canvases outside any folder
+	Tag           string     `form:"tag" binding:"max=64"`
+	Favorites     bool       `form:"favorites"`
+	Sort          string     `form:"sort" binding:"omitempty,oneof=name created_at updated_at"`
+	Order         string     `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+type saveParams struct {
+	// Creates the canvas from a template instead of the request body, only when creating a new canvas
+	TemplateId string `form:"template_id"`
+	Name       string `form:"name" binding:"max=255"`
+}
+
 func Index(c *gin.Context) {
-	var params indexParams = indexParams{
-		IndexParams: controllers.IndexParams{
-			Page:  1,
-			Limit: 100,
-			With:  make([]string, 0),
+	var params searchParams = searchParams{
+		indexParams: indexParams{
+			IndexParams: controllers.IndexParams{
+				Page:  1,
+				Limit: 100,
+				With:  make([]string, 0),
+			},
 		},
 	}
 
@@ -48,7 +114,19 @@ func Index(c *gin.Context) {
 	}
 	defer tx.Commit()
 
-	canvases, err := canvas_model.GetAll(tx, params.Limit, params.Page)
+	filter := canvas_model.Filter{
+		Search:        strings.TrimSpace(params.Search),
+		Ownership:     params.Ownership,
+		UpdatedAfter:  params.UpdatedAfter,
+		UpdatedBefore: params.UpdatedBefore,
+		FolderId:      params.FolderId,
+		Tag:           strings.TrimSpace(params.Tag),
+		Favorites:     params.Favorites,
+		Sort:          params.Sort,
+		Order:         params.Order,
+	}
+
+	canvases, total, err := canvas_model.GetAll(tx, filter, params.Limit, params.Page)
 	if err != nil {
 		tx.Rollback()
 		error_service.InternalError(c, err.Error())
@@ -66,6 +144,11 @@ func Index(c *gin.Context) {
 
 	response_service.SetJSON(c, gin.H{
 		"data": resp,
+		"meta": gin.H{
+			"total": total,
+			"page":  params.Page,
+			"limit": params.Limit,
+		},
 	})
 }
 
@@ -116,12 +199,34 @@ func Save(c *gin.Context) {
 	var id string = c.Param("canvas_id")
 	var err error = nil
 
-	var canvasData canvas_service.CanvasData
-	if err := c.ShouldBindJSON(&canvasData); err != nil {
-		c.Error(err).SetType(gin.ErrorTypeBind)
+	var params saveParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+	if params.TemplateId != "" && id != "" {
+		error_service.PublicError(c, "A template can only be used when creating a canvas", http.StatusUnprocessableEntity, "template_id", params.TemplateId, "canvas")
 		return
 	}
 
+	var canvasData canvas_service.CanvasData
+	if params.TemplateId == "" {
+		// Decoded without validating, validateAndNormalize checks the data once it's normalized
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.CanvasMaxBytes())
+		if err := json.NewDecoder(c.Request.Body).Decode(&canvasData); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				error_service.PublicError(c, fmt.Sprintf("Canvas data must be at most %d bytes", config.CanvasMaxBytes()), http.StatusRequestEntityTooLarge, "", "", "canvas")
+				return
+			}
+			c.Error(err).SetType(gin.ErrorTypeBind)
+			return
+		}
+		if !validateAndNormalize(c, &canvasData) {
+			return
+		}
+	}
+
 	logging.LogDebug("[controller]", "canvasData", canvasData)
 
 	tx, err := database_config.DB(c)
@@ -131,6 +236,40 @@ func Save(c *gin.Context) {
 		return
 	}
 
+	if params.TemplateId != "" {
+		template, err := canvas_template_model.Get(tx, params.TemplateId)
+		if err != nil {
+			error_service.PublicError(c, "Could not find canvas template", http.StatusNotFound, "template_id", params.TemplateId, "canvas")
+			return
+		}
+
+		// Fresh piece IDs so canvases made from the same template don't share them
+		if template.CanvasData.PiecesManager != nil {
+			for _, piece := range template.CanvasData.PiecesManager.Pieces {
+				if piece != nil {
+					piece.ID = ""
+				}
+			}
+		}
+		canvasData = template.CanvasData
+		canvasData.Name = template.Name
+		if name := strings.TrimSpace(params.Name); name != "" {
+			canvasData.Name = name
+		}
+		if !validateAndNormalize(c, &canvasData) {
+			return
+		}
+	}
+
+	if id != "" {
+		// Viewers can read the canvas, so tell them they may not change it rather than that it does not exist
+		role, err := canvas_model.GetRole(tx, id)
+		if err == nil && role == model.CanvasRoleViewer {
+			error_service.PublicError(c, "Viewers can not change the canvas", http.StatusForbidden, "canvas_id", id, "canvas")
+			return
+		}
+	}
+
 	canvas := &model.Canvas{}
 	canvas.ID = id
 	canvas.CanvasData = canvasData
@@ -152,6 +291,400 @@ func Save(c *gin.Context) {
 		"canvas": canvas,
 	})
 	tx.Commit()
+
+	controllers.SnapshotCanvasVersion(c, canvas.ID, model.CanvasVersionSourceRest)
+	controllers.RefreshCanvasThumbnail(c, canvas)
+}
+
+// Every canvas write goes through here, whatever the data came from: a request body, an import, a template or another canvas.
+// Paths are simplified and bounds recomputed before validating, so what gets checked is what gets saved. Responds with a 422 when invalid
+func validateAndNormalize(c *gin.Context, canvasData *canvas_service.CanvasData) bool {
+	canvasData.SimplifyPaths(config.CanvasPathTolerance())
+	canvasData.Normalize()
+
+	err := binding.Validator.ValidateStruct(canvasData)
+	if err != nil {
+		error_service.ValidationError(c, err)
+		return false
+	}
+	return true
+}
+
+// Creates a new canvas from an uploaded SVG file
+func Import(c *gin.Context) {
+	file, fileHeader, ok := openImportFile(c, "An SVG file is required")
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	canvasData, err := canvas_service.ImportSVG(file)
+	if err != nil {
+		error_service.PublicError(c, fmt.Sprintf("Could not import SVG: %v", err), http.StatusUnprocessableEntity, "file", fileHeader.Filename, "canvas")
+		return
+	}
+
+	saveImportedCanvas(c, canvasData, fileHeader.Filename, gin.H{})
+}
+
+// Creates a new canvas from an uploaded Excalidraw scene, see the excalidraw service for what gets lost on the way
+func ImportExcalidraw(c *gin.Context) {
+	file, fileHeader, ok := openImportFile(c, "An Excalidraw file is required")
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var scene excalidraw_service.Scene
+	err := json.NewDecoder(file).Decode(&scene)
+	if err != nil {
+		error_service.PublicError(c, fmt.Sprintf("Could not read Excalidraw file: %v", err), http.StatusUnprocessableEntity, "file", fileHeader.Filename, "canvas")
+		return
+	}
+
+	canvasData, skipped, err := excalidraw_service.Import(scene)
+	if err != nil {
+		error_service.PublicError(c, fmt.Sprintf("Could not import Excalidraw file: %v", err), http.StatusUnprocessableEntity, "file", fileHeader.Filename, "canvas")
+		return
+	}
+
+	saveImportedCanvas(c, canvasData, fileHeader.Filename, gin.H{
+		"skipped": skipped,
+	})
+}
+
+// Opens the uploaded "file" form field, responding with an error when there isn't one
+func openImportFile(c *gin.Context, missingMessage string) (multipart.File, *multipart.FileHeader, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.CanvasImportMaxBytes())
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		error_service.PublicError(c, missingMessage, http.StatusUnprocessableEntity, "file", "", "canvas")
+		return nil, nil, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return nil, nil, false
+	}
+
+	return file, fileHeader, true
+}
+
+// Saves imported canvas data as a new canvas, named from the "name" form field, the imported data or the file name
+func saveImportedCanvas(c *gin.Context, canvasData canvas_service.CanvasData, filename string, extra gin.H) {
+	if name := strings.TrimSpace(c.PostForm("name")); name != "" {
+		canvasData.Name = name
+	} else if canvasData.Name == "" {
+		canvasData.Name = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	if !validateAndNormalize(c, &canvasData) {
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvas := &model.Canvas{}
+	canvas.CanvasData = canvasData
+
+	err = canvas.Save(tx)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	resp := gin.H{
+		"msg":    fmt.Sprintf("Successfully imported canvas with id: %v", canvas.ID),
+		"canvas": canvas,
+	}
+	maps.Copy(resp, extra)
+	response_service.SetJSON(c, resp)
+	tx.Commit()
+
+	controllers.SnapshotCanvasVersion(c, canvas.ID, model.CanvasVersionSourceRest)
+	controllers.RefreshCanvasThumbnail(c, canvas)
+}
+
+// Moves one of the user's own canvases into one of their folders
+func MoveToFolder(c *gin.Context) {
+	var id string = c.Param("canvas_id")
+
+	var body moveToFolderBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvas := &model.Canvas{}
+	canvas.ID = id
+
+	err = canvas.MoveToFolder(tx, body.FolderId)
+	if errors.Is(err, model.ErrFolderNotFound) {
+		error_service.PublicError(c, "Could not find folder", http.StatusUnprocessableEntity, "folder_id", *body.FolderId, "canvas")
+		return
+	}
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+
+	err = relations_service.Load(tx, canvas.GetRelations(), canvas, []string{"folder"})
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"msg":    fmt.Sprintf("Successfully moved canvas with id: %v", canvas.ID),
+		"canvas": canvas,
+	})
+	tx.Commit()
+}
+
+// Pins a canvas for the user
+func Favorite(c *gin.Context) {
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	favorite := &model.CanvasFavorite{CanvasId: id}
+	err = favorite.Insert(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"msg":      fmt.Sprintf("Successfully pinned canvas with id: %v", id),
+		"favorite": favorite,
+	})
+	tx.Commit()
+}
+
+// Unpins a canvas for the user
+func Unfavorite(c *gin.Context) {
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	favorite := &model.CanvasFavorite{CanvasId: id}
+	err = favorite.Delete(tx)
+	if err != nil {
+		error_service.PublicError(c, "Canvas is not pinned", http.StatusNotFound, "canvas_id", id, "canvas_favorite")
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"message": fmt.Sprintf("Successfully unpinned canvas with id %v", id),
+		"data":    response_service.BuildResponse(*favorite),
+	})
+	tx.Commit()
+}
+
+// Lists the user's deleted canvases, which are purged once they have been in the trash for longer than the retention period
+func Trash(c *gin.Context) {
+	var params indexParams = indexParams{
+		IndexParams: controllers.IndexParams{
+			Page:  1,
+			Limit: 100,
+			With:  make([]string, 0),
+		},
+	}
+
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvases, err := canvas_model.GetAllDeleted(tx, params.Limit, params.Page)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	err = relations_service.LoadBatch(tx, model.CanvasRelations, canvases, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	retention := config.CanvasTrashRetention()
+	resp := make([]any, 0, len(canvases))
+	for _, canvas := range canvases {
+		canvasResp := response_service.BuildResponse(canvas).(map[string]any)
+		canvasResp["purge_at"] = canvas.DeletedAt.Add(retention)
+		resp = append(resp, canvasResp)
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"data": resp,
+	})
+	tx.Commit()
+}
+
+// Restores a canvas from the user's trash
+func Restore(c *gin.Context) {
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvas := &model.Canvas{}
+	canvas.ID = id
+
+	err = canvas.Restore(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not find deleted canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+
+	err = relations_service.Load(tx, canvas.GetRelations(), canvas, []string{"user", "canvas_shared_invitations", "canvas_shared_accesses"})
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"msg":    fmt.Sprintf("Successfully restored canvas with id: %v", canvas.ID),
+		"canvas": canvas,
+	})
+	tx.Commit()
+}
+
+// Permanently deletes a canvas from the user's trash, along with its versions, thumbnail and sharing
+func Purge(c *gin.Context) {
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvas := &model.Canvas{}
+	canvas.ID = id
+
+	err = canvas.Purge(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not find deleted canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"message": fmt.Sprintf("Permanently deleted canvas with id %v", canvas.ID),
+	})
+	tx.Commit()
+}
+
+// Creates a new canvas owned by the caller with a copy of the canvas' data
+func Duplicate(c *gin.Context) {
+	var id string = c.Param("canvas_id")
+
+	// The body is optional
+	var body duplicateBody
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	// Validate user owns canvas or has access to canvas
+	source, err := canvas_model.Get(tx, id)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+
+	userUuid := auth_service.Auth(c)
+	if body.CopySharing && source.UserId != userUuid {
+		error_service.PublicError(c, "Only the canvas owner can copy its sharing settings", http.StatusForbidden, "copy_sharing", "true", "canvas")
+		return
+	}
+
+	// The live room may have changes which are not saved yet
+	canvasData := websocket_service.GetCanvasData(source)
+	canvasData, err = canvasData.DeepCopy()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	canvasData.Name = fmt.Sprintf("%s (copy)", canvasData.Name)
+	if !validateAndNormalize(c, &canvasData) {
+		return
+	}
+
+	canvas := &model.Canvas{}
+	canvas.CanvasData = canvasData
+
+	err = canvas.Save(tx)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	if body.CopySharing {
+		err = canvas_shared_access_model.CopySharedAccesses(tx, source.ID, canvas.ID)
+		if err != nil {
+			error_service.InternalError(c, err.Error())
+			return
+		}
+	}
+
+	err = relations_service.Load(tx, canvas.GetRelations(), canvas, []string{"user", "canvas_shared_accesses"})
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"msg":    fmt.Sprintf("Successfully duplicated canvas %v as %v", source.ID, canvas.ID),
+		"canvas": canvas,
+	})
+	tx.Commit()
+
+	controllers.SnapshotCanvasVersion(c, canvas.ID, model.CanvasVersionSourceRest)
+	controllers.RefreshCanvasThumbnail(c, canvas)
 }
 
 func Delete(c *gin.Context) {
@@ -186,14 +719,186 @@ func Delete(c *gin.Context) {
 	tx.Commit()
 }
 
+// Renders the canvas as a standalone SVG document
+func ExportSVG(c *gin.Context) {
+	var params exportParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvas, err := canvas_model.Get(tx, id)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+	tx.Commit()
+
+	// The live room may have changes which are not saved yet
+	svg := canvas_service.RenderSVG(websocket_service.GetCanvasData(canvas), canvas_service.SVGOptions{
+		Crop:    params.Crop,
+		Padding: params.Padding,
+	})
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"canvas-%s.svg\"", canvas.ID))
+	c.Data(http.StatusOK, "image/svg+xml", svg)
+}
+
+// Renders the canvas as a PNG image
+func ExportPNG(c *gin.Context) {
+	var params exportPNGParams = exportPNGParams{
+		Scale: 1,
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvas, err := canvas_model.Get(tx, id)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+	tx.Commit()
+
+	// The live room may have changes which are not saved yet
+	image, err := canvas_service.RenderPNG(websocket_service.GetCanvasData(canvas), canvas_service.RasterOptions{
+		Crop:    params.Crop,
+		Padding: params.Padding,
+		Scale:   params.Scale,
+		MaxSize: config.CanvasExportMaxSize(),
+	})
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"canvas-%s.png\"", canvas.ID))
+	c.Data(http.StatusOK, "image/png", image)
+}
+
+// Converts the canvas to an Excalidraw scene file
+func ExportExcalidraw(c *gin.Context) {
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvas, err := canvas_model.Get(tx, id)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+	tx.Commit()
+
+	// The live room may have changes which are not saved yet
+	scene, err := json.Marshal(excalidraw_service.Export(websocket_service.GetCanvasData(canvas)))
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"canvas-%s.excalidraw\"", canvas.ID))
+	c.Data(http.StatusOK, "application/json", scene)
+}
+
+// Lists the pieces intersecting a viewport, in drawing order, so clients can load huge canvases a bit at a time
+func Pieces(c *gin.Context) {
+	var params piecesParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	view := canvas_service.Bounds{Left: *params.Left, Top: *params.Top, Right: *params.Right, Bottom: *params.Bottom}
+	if err := view.ValidateView(); err != nil {
+		error_service.PublicError(c, "Left must not be greater than right, nor top greater than bottom", http.StatusUnprocessableEntity, "left", "", "canvas")
+		return
+	}
+
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	canvas, err := canvas_model.Get(tx, id)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+	tx.Commit()
+
+	// From the live room when there is one, it has unsaved changes and keeps its index between requests
+	pieces := websocket_service.GetPiecesIn(canvas, view)
+
+	response_service.SetJSON(c, gin.H{
+		"data": pieces,
+		"meta": gin.H{
+			"count": len(pieces),
+		},
+	})
+}
+
+// Lists who is currently connected to the canvas over websockets
+func Presence(c *gin.Context) {
+	var id string = c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	// Validate user owns canvas or has access to canvas
+	canvas, err := canvas_model.Get(tx, id)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", id, "canvas")
+		return
+	}
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": websocket_service.GetPresence(canvas.ID),
+	})
+}
+
 func Websocket(c *gin.Context) {
 	claims := auth_service.GetClaims(c)
 	userUuid := claims.Subject
 
 	// Parse query params
-	var params getParams = getParams{
-		GetParams: controllers.GetParams{
-			With: make([]string, 0),
+	var params websocketParams = websocketParams{
+		getParams: getParams{
+			GetParams: controllers.GetParams{
+				With: make([]string, 0),
+			},
 		},
 	}
 
@@ -202,6 +907,15 @@ func Websocket(c *gin.Context) {
 		return
 	}
 
+	// A reconnecting client only needs what it missed
+	var resume *websocket_service.Resume
+	if params.LastSeq != nil {
+		resume = &websocket_service.Resume{
+			Epoch:   params.Epoch,
+			LastSeq: *params.LastSeq,
+		}
+	}
+
 	// Validate canvas id param
 	var id string = c.Param("id")
 
@@ -218,23 +932,22 @@ func Websocket(c *gin.Context) {
 		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "id", id, "canvas")
 		return
 	}
+
+	role, err := canvas_model.GetRole(tx, id)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
 	tx.Commit()
 
 	chResume := make(chan *websocket_service.Client, 1)
 
 	conn := websocket_service.Connect(c)
-	websocket_service.Join(userUuid, canvas, conn, chResume)
+	// Joining sends the client a snapshot of the live canvas, or replays the events it missed
+	websocket_service.Join(userUuid, claims.Email, role, resume, params.WithoutPieces, canvas, conn, chResume)
 
 	client := <-chResume
 
-	canvas.CanvasData = client.GetRoom().Canvas.CanvasData
-	canvasMap := service.ToMapStringAny(canvas)
-	msg := websocket_service.RoomMessage{
-		Event: "update-canvas-data",
-		Data:  canvasMap,
-	}
-	client.Send(msg)
-
 	conn.SetCloseHandler(func(code int, text string) error {
 		logging.LogInfo("WebSocket", "Connection closed", nil)
 		client.Leave()
@@ -242,7 +955,7 @@ func Websocket(c *gin.Context) {
 	})
 
 	// Go rotine for reading websocket messages
-	go client.Reader(c)
+	go client.Reader()
 
 	// Go rotine for writing websocket messages
 	client.Writer()
@@ -0,0 +1,53 @@
+package canvas_controller
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"qolboard-api/config"
+	error_service "qolboard-api/services/error"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestImportRejectsTooManyPieces(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	error_service.SetUpValidator()
+
+	var svg strings.Builder
+	svg.WriteString(`<svg xmlns="http://www.w3.org/2000/svg">`)
+	for i := 0; i <= config.CanvasMaxPieces(); i++ {
+		fmt.Fprintf(&svg, `<path d="M%d 0L%d 1" stroke="#000"/>`, i, i)
+	}
+	svg.WriteString(`</svg>`)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "huge.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte(svg.String()))
+	form.Close()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/user/canvas/import", &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+
+	// Rejected before a transaction is started, so no database is needed
+	Import(c)
+
+	errs := c.Errors.ByType(gin.ErrorTypeBind)
+	if len(errs) != 1 {
+		t.Fatalf("expected a validation error, got %v", c.Errors)
+	}
+	code, formatted := error_service.HandleGinError(*errs[0])
+	if code != http.StatusUnprocessableEntity || len(formatted) != 1 || formatted[0].Field != "pieces" {
+		t.Errorf("expected a 422 for the pieces, got %d %+v", code, formatted)
+	}
+}
@@ -0,0 +1,336 @@
+package canvas_comment_controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	database_config "qolboard-api/config/database"
+	"qolboard-api/controllers"
+	model "qolboard-api/models"
+	canvas_model "qolboard-api/models/canvas"
+	canvas_comment_model "qolboard-api/models/canvas_comment"
+	"qolboard-api/services/database"
+	error_service "qolboard-api/services/error"
+	relations_service "qolboard-api/services/relations"
+	response_service "qolboard-api/services/response"
+	websocket_service "qolboard-api/services/websocket"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+type indexParams struct {
+	controllers.IndexParams
+	Resolved *bool `form:"resolved"` // Omit for both open and resolved threads
+}
+
+type getParams struct {
+	controllers.GetParams
+}
+
+type createBody struct {
+	Body     string   `json:"body" binding:"required,max=10000"`
+	ParentId *string  `json:"parent_id" binding:"omitempty,uuid"` // Set to reply to a thread, replies have no anchor
+	X        *float64 `json:"x"`
+	Y        *float64 `json:"y"`
+	PieceId  *string  `json:"piece_id" binding:"omitempty,max=64"`
+}
+
+type updateBody struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+// Lists the comment threads on a canvas, use with[]=replies.user for their replies
+func Index(c *gin.Context) {
+	params := indexParams{
+		IndexParams: controllers.IndexParams{
+			Page:  1,
+			Limit: 100,
+			With:  make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	canvasId := c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	comments, err := canvas_comment_model.GetAll(tx, canvasId, params.Resolved, params.Limit, params.Page)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	err = relations_service.LoadBatch(tx, model.CanvasCommentRelations, comments, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(comments),
+	})
+}
+
+func Get(c *gin.Context) {
+	params := getParams{
+		GetParams: controllers.GetParams{
+			With: make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	commentId := c.Param("canvas_comment_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	comment, err := canvas_comment_model.Get(tx, commentId)
+	if err != nil {
+		error_service.PublicError(c, "Could not find comment", http.StatusNotFound, "canvas_comment_id", commentId, "canvas_comment")
+		return
+	}
+
+	err = relations_service.Load(tx, model.CanvasCommentRelations, comment, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(*comment),
+	})
+}
+
+// Starts a thread anchored to a point or a piece, or replies to one. Anyone with access to the canvas may comment, viewers included
+func Create(c *gin.Context) {
+	var body createBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	canvasId := c.Param("canvas_id")
+	text := strings.TrimSpace(body.Body)
+	if text == "" {
+		error_service.PublicError(c, "Comment cannot be blank", http.StatusUnprocessableEntity, "body", body.Body, "canvas_comment")
+		return
+	}
+
+	hasPoint := body.X != nil || body.Y != nil
+	hasPiece := body.PieceId != nil
+	switch {
+	case body.ParentId != nil && (hasPoint || hasPiece):
+		error_service.PublicError(c, "Replies are anchored by their thread and cannot have an x, y or piece_id", http.StatusUnprocessableEntity, "parent_id", *body.ParentId, "canvas_comment")
+		return
+	case body.ParentId == nil && hasPoint == hasPiece:
+		error_service.PublicError(c, "Comments must be anchored to either a point with x and y, or a piece_id", http.StatusUnprocessableEntity, "piece_id", "", "canvas_comment")
+		return
+	case hasPoint && (body.X == nil || body.Y == nil):
+		error_service.PublicError(c, "Both x and y are required to anchor a comment to a point", http.StatusUnprocessableEntity, "x", "", "canvas_comment")
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	canvas, err := canvas_model.Get(tx, canvasId)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", canvasId, "canvas")
+		return
+	}
+
+	if hasPiece {
+		// Checked against the live canvas, the piece may have been drawn moments ago and not saved yet
+		canvasData := websocket_service.GetCanvasData(canvas)
+		if canvasData.PiecesManager == nil || canvasData.PiecesManager.IndexOf(*body.PieceId) < 0 {
+			error_service.PublicError(c, "Could not find piece", http.StatusUnprocessableEntity, "piece_id", *body.PieceId, "canvas_comment")
+			return
+		}
+	}
+
+	comment := &model.CanvasComment{
+		CanvasId: canvasId,
+		ParentId: body.ParentId,
+		Body:     text,
+		X:        body.X,
+		Y:        body.Y,
+		PieceId:  body.PieceId,
+	}
+
+	err = comment.Insert(tx)
+	if errors.Is(err, model.ErrCanvasCommentThreadNotFound) {
+		error_service.PublicError(c, "Could not find comment thread", http.StatusUnprocessableEntity, "parent_id", *body.ParentId, "canvas_comment")
+		return
+	}
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", canvasId, "canvas")
+		return
+	}
+
+	if !commitAndBroadcast(c, tx, "comment-add", comment) {
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"msg":     fmt.Sprintf("Successfully commented on canvas %v", canvasId),
+		"comment": comment,
+	})
+}
+
+// Edits one of the user's own comments
+func Update(c *gin.Context) {
+	var body updateBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	commentId := c.Param("canvas_comment_id")
+	text := strings.TrimSpace(body.Body)
+	if text == "" {
+		error_service.PublicError(c, "Comment cannot be blank", http.StatusUnprocessableEntity, "body", body.Body, "canvas_comment")
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	comment := &model.CanvasComment{
+		Body: text,
+	}
+	comment.ID = commentId
+
+	err = comment.Update(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not find comment", http.StatusNotFound, "canvas_comment_id", commentId, "canvas_comment")
+		return
+	}
+
+	if !commitAndBroadcast(c, tx, "comment-update", comment) {
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"msg":     fmt.Sprintf("Successfully updated comment with id: %v", comment.ID),
+		"comment": comment,
+	})
+}
+
+func Resolve(c *gin.Context) {
+	setResolved(c, true)
+}
+
+func Unresolve(c *gin.Context) {
+	setResolved(c, false)
+}
+
+func setResolved(c *gin.Context, resolved bool) {
+	commentId := c.Param("canvas_comment_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	comment := &model.CanvasComment{}
+	comment.ID = commentId
+
+	err = comment.SetResolved(tx, resolved)
+	if err != nil {
+		// Replies can't be resolved on their own, only their thread
+		error_service.PublicError(c, "Could not find comment thread", http.StatusNotFound, "canvas_comment_id", commentId, "canvas_comment")
+		return
+	}
+
+	if !commitAndBroadcast(c, tx, "comment-update", comment) {
+		return
+	}
+
+	action := "reopened"
+	if resolved {
+		action = "resolved"
+	}
+	response_service.SetJSON(c, gin.H{
+		"msg":     fmt.Sprintf("Successfully %s comment thread with id: %v", action, comment.ID),
+		"comment": comment,
+	})
+}
+
+// Deletes one of the user's own comments, or any comment on a canvas the user owns
+func Delete(c *gin.Context) {
+	commentId := c.Param("canvas_comment_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	comment := &model.CanvasComment{}
+	comment.ID = commentId
+
+	err = comment.Delete(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not delete comment", http.StatusNotFound, "canvas_comment_id", commentId, "canvas_comment")
+		return
+	}
+
+	if !commitAndBroadcast(c, tx, "comment-remove", comment) {
+		return
+	}
+
+	response_service.SetJSON(c, gin.H{
+		"message": fmt.Sprintf("Successfully deleted comment with id %v", comment.ID),
+		"data":    response_service.BuildResponse(*comment),
+	})
+}
+
+// Loads the comment's author so clients can show it straight away, then lets everyone in the canvas room know about the change
+func commitAndBroadcast(c *gin.Context, tx *sqlx.Tx, event string, comment *model.CanvasComment) bool {
+	err := relations_service.Load(tx, model.CanvasCommentRelations, comment, []string{"user"})
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return false
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return false
+	}
+
+	websocket_service.BroadcastComment(event, comment)
+	return true
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	database_config "qolboard-api/config/database"
+	model "qolboard-api/models"
+	canvas_thumbnail_model "qolboard-api/models/canvas_thumbnail"
+	canvas_version_model "qolboard-api/models/canvas_version"
+	auth_service "qolboard-api/services/auth"
+	"qolboard-api/services/database"
+	"qolboard-api/services/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Snapshots a version of the canvas in a transaction of its own, call it once the canvas save committed.
+// Versions are history, a failed snapshot is logged and never fails the save
+func SnapshotCanvasVersion(c *gin.Context, canvasId string, source string) {
+	tx, err := database_config.DB(c)
+	if err != nil {
+		logging.LogError("[controller]", "Error snapshotting canvas version", err)
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	userUuid := auth_service.Auth(c)
+	err = canvas_version_model.Snapshot(tx, canvasId, &userUuid, source, 0)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		logging.LogError("[controller]", "Error snapshotting canvas version", err)
+	}
+}
+
+// Refreshes the canvas' thumbnail in a transaction of its own, call it once the canvas save committed.
+// Thumbnails are a cache, a failed refresh is logged and never fails the save
+func RefreshCanvasThumbnail(c *gin.Context, canvas *model.Canvas) {
+	tx, err := database_config.DB(c)
+	if err != nil {
+		logging.LogError("[controller]", "Error refreshing canvas thumbnail", err)
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	err = canvas_thumbnail_model.Refresh(tx, canvas)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		logging.LogError("[controller]", "Error refreshing canvas thumbnail", err)
+	}
+}
@@ -1,7 +1,10 @@
 package canvas_shared_access_controller
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"net/http"
 	database_config "qolboard-api/config/database"
 	"qolboard-api/controllers"
 	model "qolboard-api/models"
@@ -9,6 +12,7 @@ import (
 	error_service "qolboard-api/services/error"
 	relations_service "qolboard-api/services/relations"
 	response_service "qolboard-api/services/response"
+	websocket_service "qolboard-api/services/websocket"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,6 +21,10 @@ type IndexParams struct {
 	controllers.IndexParams
 }
 
+type updateBody struct {
+	Role string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
 func Index(c *gin.Context) {
 	// Get query params
 	params := IndexParams{
@@ -59,6 +67,48 @@ func Index(c *gin.Context) {
 	tx.Commit()
 }
 
+func Update(c *gin.Context) {
+	// Parse id
+	id := c.Param("canvas_shared_access_id")
+
+	var body updateBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	defer tx.Rollback()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	csa := model.CanvasSharedAccess{}
+	csa.ID = id
+	csa.Role = body.Role
+
+	err = csa.UpdateRole(tx)
+	if errors.Is(err, sql.ErrNoRows) {
+		error_service.PublicError(c, "Canvas shared access not found", http.StatusNotFound, "canvas_shared_access_id", id, "canvas_shared_access")
+		return
+	}
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	// Apply the new role to any live websocket connections straight away
+	websocket_service.SetRole(csa.CanvasId, csa.UserId, csa.Role)
+
+	response_service.SetJSON(c, gin.H{
+		"message": fmt.Sprintf("Successfully updated canvas shared access with id %v", csa.ID),
+		"data":    response_service.BuildResponse(csa),
+	})
+}
+
 func Delete(c *gin.Context) {
 	// Parse id
 	id := c.Param("canvas_shared_access_id")
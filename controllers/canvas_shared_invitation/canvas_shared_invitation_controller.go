@@ -1,7 +1,10 @@
 package canvas_shared_invitation_controller
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	database_config "qolboard-api/config/database"
@@ -14,6 +17,7 @@ import (
 	relations_service "qolboard-api/services/relations"
 	response_service "qolboard-api/services/response"
 	websocket_service "qolboard-api/services/websocket"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,15 +29,30 @@ type IndexQuery struct {
 	With     []string `form:"with[]"`
 }
 
+type createBody struct {
+	Role      string     `json:"role" binding:"omitempty,oneof=viewer editor"`
+	ExpiresAt *time.Time `json:"expires_at" binding:"omitempty,gt"`
+	MaxUses   *int       `json:"max_uses" binding:"omitempty,gte=1"`
+}
+
 func Create(c *gin.Context) {
 	var claims auth_service.Claims = *auth_service.GetClaims(c)
 
 	var canvasId string = c.Param("canvas_id")
 	var err error = nil
 
+	// The body is optional, invitations grant editor access by default
+	body := createBody{
+		Role: model.CanvasRoleEditor,
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		error_service.ValidationError(c, err)
+		return
+	}
+
 	var canvasSharedInvitation *model.CanvasSharedInvitation
 
-	canvasSharedInvitation, err = canvas_shared_invitation_model.NewCanvasSharedInvitation(claims.Subject, canvasId)
+	canvasSharedInvitation, err = canvas_shared_invitation_model.NewCanvasSharedInvitation(claims.Subject, canvasId, body.Role, body.ExpiresAt, body.MaxUses)
 	if err != nil {
 		error_service.InternalError(c, err.Error())
 		return
@@ -47,6 +66,10 @@ func Create(c *gin.Context) {
 	}
 
 	err = canvasSharedInvitation.Save(tx)
+	if errors.Is(err, sql.ErrNoRows) {
+		error_service.PublicError(c, "Canvas not found", http.StatusNotFound, "canvas_id", canvasId, "canvas")
+		return
+	}
 	if err != nil {
 		error_service.InternalError(c, err.Error())
 		return
@@ -151,6 +174,10 @@ func AcceptInvite(c *gin.Context) {
 	}
 
 	csi, err := canvas_shared_invitation_model.GetByCode(tx, canvasId, paramCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		error_service.PublicError(c, "Invitation not found", http.StatusNotFound, "code", "", "canvas_shared_invitation")
+		return
+	}
 	if err != nil {
 		error_service.InternalError(c, err.Error())
 		return
@@ -158,22 +185,20 @@ func AcceptInvite(c *gin.Context) {
 
 	// Check to ensure we do not create a "shared access" for the canvas owner
 	if csi.UserId != claims.Subject {
-		// Create shared access
-		var csa model.CanvasSharedAccess = model.CanvasSharedAccess{
-			UserId:                   claims.Subject,
-			CanvasId:                 canvasId,
-			CanvasSharedInvitationId: csi.ID,
+		_, err = canvas_shared_invitation_model.Accept(tx, &csi, time.Now())
+		if err != nil {
+			invitationError(c, err)
+			return
 		}
-
-		csa.Insert(tx)
 	}
 
 	canvas, err := canvas_model.Get(tx, csi.CanvasId)
 	if err != nil {
 		error_service.PublicError(c, "Could not find canvas", 404, "id", string(csi.ID), "canvas")
+		return
 	}
 
-	err = relations_service.Load(tx, model.CanvasRelations, canvas, []string{"user", "canvas_shared_invitations", "canvas_shared_accesses.user"})
+	err = relations_service.Load(tx, model.CanvasRelations, canvas, []string{"user", "canvas_shared_accesses.user"})
 	if err != nil {
 		error_service.InternalError(c, err.Error())
 		return
@@ -188,3 +213,16 @@ func AcceptInvite(c *gin.Context) {
 	locatoin := fmt.Sprintf("%s/canvas/%v", appHost, canvasId)
 	c.Redirect(http.StatusFound, locatoin)
 }
+
+func invitationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvitationRevoked):
+		error_service.PublicError(c, "This invitation link has been revoked", http.StatusGone, "code", "", "canvas_shared_invitation")
+	case errors.Is(err, model.ErrInvitationExpired):
+		error_service.PublicError(c, "This invitation link has expired", http.StatusGone, "code", "", "canvas_shared_invitation")
+	case errors.Is(err, model.ErrInvitationExhausted):
+		error_service.PublicError(c, "This invitation link has reached its maximum number of uses", http.StatusGone, "code", "", "canvas_shared_invitation")
+	default:
+		error_service.InternalError(c, err.Error())
+	}
+}
@@ -0,0 +1,126 @@
+package canvas_tag_controller
+
+import (
+	"fmt"
+	"net/http"
+	database_config "qolboard-api/config/database"
+	"qolboard-api/controllers"
+	model "qolboard-api/models"
+	canvas_tag_model "qolboard-api/models/canvas_tag"
+	"qolboard-api/services/database"
+	error_service "qolboard-api/services/error"
+	response_service "qolboard-api/services/response"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type indexParams struct {
+	controllers.IndexParams
+}
+
+type createBody struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+// Lists the tag names in use on canvases the user has access to
+func Index(c *gin.Context) {
+	params := indexParams{
+		IndexParams: controllers.IndexParams{
+			Page:  1,
+			Limit: 100,
+			With:  make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	tags, err := canvas_tag_model.GetAll(tx, params.Limit, params.Page)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(tags),
+	})
+}
+
+// Tags a canvas the user can edit
+func Create(c *gin.Context) {
+	var body createBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	canvasId := c.Param("canvas_id")
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		error_service.PublicError(c, "Tag name cannot be blank", http.StatusUnprocessableEntity, "name", body.Name, "canvas_tag")
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	tag := &model.CanvasTag{
+		CanvasId: canvasId,
+		Name:     name,
+	}
+
+	err = tag.Insert(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", canvasId, "canvas")
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"msg": fmt.Sprintf("Successfully tagged canvas %v with %q", canvasId, tag.Name),
+		"tag": tag,
+	})
+}
+
+func Delete(c *gin.Context) {
+	tagId := c.Param("canvas_tag_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	tag := &model.CanvasTag{}
+	tag.ID = tagId
+
+	err = tag.Delete(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not delete canvas tag", http.StatusNotFound, "canvas_tag_id", tagId, "canvas_tag")
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"message": fmt.Sprintf("Successfully deleted canvas tag with id %v", tag.ID),
+		"data":    response_service.BuildResponse(*tag),
+	})
+}
@@ -0,0 +1,187 @@
+package canvas_template_controller
+
+import (
+	"fmt"
+	"net/http"
+	database_config "qolboard-api/config/database"
+	"qolboard-api/controllers"
+	model "qolboard-api/models"
+	canvas_model "qolboard-api/models/canvas"
+	canvas_template_model "qolboard-api/models/canvas_template"
+	"qolboard-api/services/database"
+	error_service "qolboard-api/services/error"
+	relations_service "qolboard-api/services/relations"
+	response_service "qolboard-api/services/response"
+	websocket_service "qolboard-api/services/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+type indexParams struct {
+	controllers.IndexParams
+}
+
+type getParams struct {
+	controllers.GetParams
+}
+
+type createBody struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description" binding:"max=2000"`
+}
+
+// Lists the built in system templates and the user's own templates
+func Index(c *gin.Context) {
+	params := indexParams{
+		IndexParams: controllers.IndexParams{
+			Page:  1,
+			Limit: 100,
+			With:  make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	templates, err := canvas_template_model.GetAll(tx, params.Limit, params.Page)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	err = relations_service.LoadBatch(tx, model.CanvasTemplateRelations, templates, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(templates),
+	})
+}
+
+func Get(c *gin.Context) {
+	params := getParams{
+		GetParams: controllers.GetParams{
+			With: make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	templateId := c.Param("canvas_template_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	template, err := canvas_template_model.Get(tx, templateId)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas template", http.StatusNotFound, "canvas_template_id", templateId, "canvas_template")
+		return
+	}
+
+	err = relations_service.Load(tx, model.CanvasTemplateRelations, template, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(*template),
+	})
+}
+
+// Saves a copy of a canvas the user has access to as one of their templates
+func Create(c *gin.Context) {
+	var body createBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	canvasId := c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	canvas, err := canvas_model.Get(tx, canvasId)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", canvasId, "canvas")
+		return
+	}
+
+	// The live room may have changes which are not saved yet
+	canvasData, err := websocket_service.GetCanvasData(canvas).DeepCopy()
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	template := &model.CanvasTemplate{
+		Name:        body.Name,
+		Description: body.Description,
+		CanvasData:  canvasData,
+	}
+
+	err = template.Insert(tx)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"msg":      fmt.Sprintf("Successfully saved canvas %v as template %v", canvas.ID, template.ID),
+		"template": template,
+	})
+}
+
+func Delete(c *gin.Context) {
+	templateId := c.Param("canvas_template_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	template := &model.CanvasTemplate{}
+	template.ID = templateId
+
+	err = template.Delete(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not delete canvas template", http.StatusNotFound, "canvas_template_id", templateId, "canvas_template")
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"message": fmt.Sprintf("Successfully deleted canvas template with id %v", template.ID),
+		"data":    response_service.BuildResponse(*template),
+	})
+}
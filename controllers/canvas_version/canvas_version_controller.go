@@ -0,0 +1,209 @@
+package canvas_version_controller
+
+import (
+	"fmt"
+	"net/http"
+	database_config "qolboard-api/config/database"
+	"qolboard-api/controllers"
+	model "qolboard-api/models"
+	canvas_model "qolboard-api/models/canvas"
+	canvas_version_model "qolboard-api/models/canvas_version"
+	canvas_service "qolboard-api/services/canvas"
+	"qolboard-api/services/database"
+	error_service "qolboard-api/services/error"
+	relations_service "qolboard-api/services/relations"
+	response_service "qolboard-api/services/response"
+	websocket_service "qolboard-api/services/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+type indexParams struct {
+	controllers.IndexParams
+}
+
+type getParams struct {
+	controllers.GetParams
+}
+
+type diffParams struct {
+	// Version id to compare against, compares against the current canvas data when empty
+	Against string `form:"against"`
+}
+
+func Index(c *gin.Context) {
+	params := indexParams{
+		IndexParams: controllers.IndexParams{
+			Page:  1,
+			Limit: 100,
+			With:  make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	canvasId := c.Param("canvas_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	versions, err := canvas_version_model.GetAllForCanvas(tx, canvasId, params.Limit, params.Page)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	err = relations_service.LoadBatch(tx, model.CanvasVersionRelations, versions, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(versions),
+	})
+}
+
+func Get(c *gin.Context) {
+	params := getParams{
+		GetParams: controllers.GetParams{
+			With: make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	canvasId := c.Param("canvas_id")
+	versionId := c.Param("version_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	version, err := canvas_version_model.Get(tx, canvasId, versionId)
+	if err != nil {
+		error_service.PublicError(c, "Could not find canvas version", http.StatusNotFound, "version_id", versionId, "canvas_version")
+		return
+	}
+
+	err = relations_service.Load(tx, model.CanvasVersionRelations, version, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(*version),
+	})
+}
+
+func Diff(c *gin.Context) {
+	var params diffParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	canvasId := c.Param("canvas_id")
+	versionId := c.Param("version_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	version, err := canvas_version_model.Get(tx, canvasId, versionId)
+	if err != nil || version.CanvasData == nil {
+		error_service.PublicError(c, "Could not find canvas version", http.StatusNotFound, "version_id", versionId, "canvas_version")
+		return
+	}
+
+	var against canvas_service.CanvasData
+	if params.Against != "" {
+		againstVersion, err := canvas_version_model.Get(tx, canvasId, params.Against)
+		if err != nil || againstVersion.CanvasData == nil {
+			error_service.PublicError(c, "Could not find canvas version", http.StatusNotFound, "against", params.Against, "canvas_version")
+			return
+		}
+		against = *againstVersion.CanvasData
+	} else {
+		canvas, err := canvas_model.Get(tx, canvasId)
+		if err != nil {
+			error_service.PublicError(c, "Could not find canvas", http.StatusNotFound, "canvas_id", canvasId, "canvas")
+			return
+		}
+		// The live room may have changes which are not saved yet
+		against = websocket_service.GetCanvasData(canvas)
+	}
+
+	tx.Commit()
+
+	// Describes what would change going from this version to the one compared against
+	diff := canvas_service.Diff(*version.CanvasData, against)
+
+	response_service.SetJSON(c, gin.H{
+		"data": diff,
+	})
+}
+
+func Restore(c *gin.Context) {
+	canvasId := c.Param("canvas_id")
+	versionId := c.Param("version_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	version, err := canvas_version_model.Get(tx, canvasId, versionId)
+	if err != nil || version.CanvasData == nil {
+		error_service.PublicError(c, "Could not find canvas version", http.StatusNotFound, "version_id", versionId, "canvas_version")
+		return
+	}
+
+	canvas := &model.Canvas{}
+	canvas.ID = canvasId
+	canvas.CanvasData = *version.CanvasData
+
+	err = canvas.Save(tx)
+	if err != nil {
+		error_service.PublicError(c, "Canvas not found", http.StatusNotFound, "canvas_id", canvasId, "canvas")
+		return
+	}
+
+	tx.Commit()
+
+	// Snapshot the restore, so it shows up in the history
+	controllers.SnapshotCanvasVersion(c, canvas.ID, model.CanvasVersionSourceRestore)
+	controllers.RefreshCanvasThumbnail(c, canvas)
+
+	// Built before the canvas is handed to the room, which changes its data from then on
+	data := response_service.BuildResponse(*canvas)
+
+	// Push the restored data to anyone currently editing the canvas, otherwise the room would overwrite it on its next save
+	websocket_service.SetCanvasData(canvas)
+
+	response_service.SetJSON(c, gin.H{
+		"message": fmt.Sprintf("Successfully restored canvas with id %v to version %v", canvas.ID, version.ID),
+		"data":    data,
+	})
+}
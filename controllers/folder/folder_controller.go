@@ -0,0 +1,223 @@
+package folder_controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	database_config "qolboard-api/config/database"
+	"qolboard-api/controllers"
+	model "qolboard-api/models"
+	folder_model "qolboard-api/models/folder"
+	"qolboard-api/services/database"
+	error_service "qolboard-api/services/error"
+	relations_service "qolboard-api/services/relations"
+	response_service "qolboard-api/services/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type indexParams struct {
+	controllers.IndexParams
+}
+
+type getParams struct {
+	controllers.GetParams
+}
+
+type saveBody struct {
+	Name     string  `json:"name" binding:"required,max=255"`
+	ParentId *string `json:"parent_id" binding:"omitempty,uuid"` // Omit or null for a top level folder
+}
+
+func Index(c *gin.Context) {
+	params := indexParams{
+		IndexParams: controllers.IndexParams{
+			Page:  1,
+			Limit: 100,
+			With:  make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	folders, err := folder_model.GetAll(tx, params.Limit, params.Page)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	err = relations_service.LoadBatch(tx, model.FolderRelations, folders, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(folders),
+	})
+}
+
+func Get(c *gin.Context) {
+	params := getParams{
+		GetParams: controllers.GetParams{
+			With: make([]string, 0),
+		},
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		error_service.ValidationError(c, err)
+		return
+	}
+
+	folderId := c.Param("folder_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	folder, err := folder_model.Get(tx, folderId)
+	if err != nil {
+		error_service.PublicError(c, "Could not find folder", http.StatusNotFound, "folder_id", folderId, "folder")
+		return
+	}
+
+	err = relations_service.Load(tx, model.FolderRelations, folder, params.With)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"data": response_service.BuildResponse(*folder),
+	})
+}
+
+func Create(c *gin.Context) {
+	var body saveBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	folder := &model.Folder{
+		Name:     body.Name,
+		ParentId: body.ParentId,
+	}
+
+	err = folder.Insert(tx)
+	if err != nil {
+		folderError(c, err, body.ParentId)
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"msg":    fmt.Sprintf("Successfully created folder with id: %v", folder.ID),
+		"folder": folder,
+	})
+}
+
+// Renames and moves a folder
+func Update(c *gin.Context) {
+	var body saveBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	folderId := c.Param("folder_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	folder := &model.Folder{
+		Name:     body.Name,
+		ParentId: body.ParentId,
+	}
+	folder.ID = folderId
+
+	err = folder.Update(tx)
+	if err != nil {
+		folderError(c, err, body.ParentId)
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"msg":    fmt.Sprintf("Successfully updated folder with id: %v", folder.ID),
+		"folder": folder,
+	})
+}
+
+// Deletes a folder, its subfolders and canvases move up into its parent
+func Delete(c *gin.Context) {
+	folderId := c.Param("folder_id")
+
+	tx, err := database_config.DB(c)
+	if err != nil {
+		error_service.InternalError(c, err.Error())
+		return
+	}
+	defer database.StandardDeferRollback(tx)
+
+	folder := &model.Folder{}
+	folder.ID = folderId
+
+	err = folder.Delete(tx)
+	if err != nil {
+		error_service.PublicError(c, "Could not delete folder", http.StatusNotFound, "folder_id", folderId, "folder")
+		return
+	}
+
+	tx.Commit()
+
+	response_service.SetJSON(c, gin.H{
+		"message": fmt.Sprintf("Successfully deleted folder with id %v", folder.ID),
+		"data":    response_service.BuildResponse(*folder),
+	})
+}
+
+func folderError(c *gin.Context, err error, parentId *string) {
+	value := ""
+	if parentId != nil {
+		value = *parentId
+	}
+
+	switch {
+	case errors.Is(err, model.ErrFolderNotFound):
+		error_service.PublicError(c, "Could not find parent folder", http.StatusUnprocessableEntity, "parent_id", value, "folder")
+	case errors.Is(err, model.ErrFolderCycle):
+		error_service.PublicError(c, "A folder cannot be moved into itself or one of its subfolders", http.StatusUnprocessableEntity, "parent_id", value, "folder")
+	default:
+		// The folder being updated isn't one of the user's
+		error_service.PublicError(c, "Could not find folder", http.StatusNotFound, "folder_id", c.Param("folder_id"), "folder")
+	}
+}
@@ -14,9 +14,15 @@ import (
 	"time"
 
 	database_config "qolboard-api/config/database"
+	asset_controller "qolboard-api/controllers/asset"
 	canvas_controller "qolboard-api/controllers/canvas"
+	canvas_comment_controller "qolboard-api/controllers/canvas_comment"
 	canvas_shared_access_controller "qolboard-api/controllers/canvas_shared_access"
 	canvas_shared_invitation_controller "qolboard-api/controllers/canvas_shared_invitation"
+	canvas_tag_controller "qolboard-api/controllers/canvas_tag"
+	canvas_template_controller "qolboard-api/controllers/canvas_template"
+	canvas_version_controller "qolboard-api/controllers/canvas_version"
+	folder_controller "qolboard-api/controllers/folder"
 	user_controller "qolboard-api/controllers/user"
 	auth_middleware "qolboard-api/middleware/auth"
 	cors_middleware "qolboard-api/middleware/cors"
@@ -24,6 +30,9 @@ import (
 	rate_limiting_middleware "qolboard-api/middleware/rate_limiting"
 	response_middleware "qolboard-api/middleware/response"
 	error_service "qolboard-api/services/error"
+	storage_service "qolboard-api/services/storage"
+	trash_service "qolboard-api/services/trash"
+	websocket_service "qolboard-api/services/websocket"
 
 	"github.com/gin-gonic/autotls"
 	"github.com/gin-gonic/gin"
@@ -64,6 +73,30 @@ func main() {
 
 	restHandler := controllers.NewRESTHAndler(emailCleint)
 
+	// Setup websocket rooms
+	var wsBackend websocket_service.Backend = websocket_service.NewMemoryBackend()
+	if config.WebsocketBackend() == "postgres" {
+		var err error
+		wsBackend, err = websocket_service.NewPostgresBackend(ctx, database_config.DSN(), config.WebsocketBackendChannel())
+		if err != nil {
+			logging.LogError("main", "error starting postgres websocket backend", err)
+			os.Exit(1)
+		}
+	}
+	defer wsBackend.Close()
+	websocket_service.Start(wsBackend)
+
+	// Setup asset storage
+	assetStorage, err := storage_service.NewLocalStorage(config.AssetStoragePath())
+	if err != nil {
+		logging.LogError("main", "error setting up asset storage", err)
+		os.Exit(1)
+	}
+	assetHandler := asset_controller.NewAssetHandler(assetStorage)
+
+	// Purge canvases which have been in the trash for too long
+	go trash_service.PeriodicPurge(ctx, config.CanvasTrashPurgeInterval(), config.CanvasTrashRetention(), assetStorage)
+
 	// Setup router
 	r := gin.Default()
 
@@ -107,10 +140,54 @@ func main() {
 
 		// User Canvas routes
 		rUser.POST("/canvas", canvas_controller.Save)
+		rUser.POST("/canvas/import", canvas_controller.Import)
+		rUser.POST("/canvas/import/excalidraw", canvas_controller.ImportExcalidraw)
 		rUser.GET("/canvas", canvas_controller.Index)
+		rUser.GET("/canvas/trash", canvas_controller.Trash)
+		rUser.DELETE("/canvas/trash/:canvas_id", canvas_controller.Purge)
 		rUser.GET("/canvas/:canvas_id", canvas_controller.Get)
 		rUser.POST("/canvas/:canvas_id", canvas_controller.Save)
 		rUser.DELETE("/canvas/:canvas_id", canvas_controller.Delete)
+		rUser.POST("/canvas/:canvas_id/restore", canvas_controller.Restore)
+		rUser.POST("/canvas/:canvas_id/folder", canvas_controller.MoveToFolder)
+		rUser.POST("/canvas/:canvas_id/favorite", canvas_controller.Favorite)
+		rUser.DELETE("/canvas/:canvas_id/favorite", canvas_controller.Unfavorite)
+		rUser.POST("/canvas/:canvas_id/tag", canvas_tag_controller.Create)
+		rUser.POST("/canvas/:canvas_id/duplicate", canvas_controller.Duplicate)
+		rUser.GET("/canvas/:canvas_id/presence", canvas_controller.Presence)
+		rUser.GET("/canvas/:canvas_id/pieces", canvas_controller.Pieces)
+		rUser.GET("/canvas/:canvas_id/export.svg", canvas_controller.ExportSVG)
+		rUser.GET("/canvas/:canvas_id/export.png", canvas_controller.ExportPNG)
+		rUser.GET("/canvas/:canvas_id/export.excalidraw", canvas_controller.ExportExcalidraw)
+		rUser.POST("/canvas/:canvas_id/template", canvas_template_controller.Create)
+		rUser.POST("/canvas/:canvas_id/assets", assetHandler.Upload)
+		rUser.GET("/canvas/:canvas_id/assets/:asset_id", assetHandler.Download)
+
+		rUser.GET("/canvas/template", canvas_template_controller.Index)
+		rUser.GET("/canvas/template/:canvas_template_id", canvas_template_controller.Get)
+		rUser.DELETE("/canvas/template/:canvas_template_id", canvas_template_controller.Delete)
+
+		rUser.GET("/canvas/:canvas_id/comments", canvas_comment_controller.Index)
+		rUser.POST("/canvas/:canvas_id/comments", canvas_comment_controller.Create)
+		rUser.GET("/canvas/comment/:canvas_comment_id", canvas_comment_controller.Get)
+		rUser.POST("/canvas/comment/:canvas_comment_id", canvas_comment_controller.Update)
+		rUser.DELETE("/canvas/comment/:canvas_comment_id", canvas_comment_controller.Delete)
+		rUser.POST("/canvas/comment/:canvas_comment_id/resolve", canvas_comment_controller.Resolve)
+		rUser.DELETE("/canvas/comment/:canvas_comment_id/resolve", canvas_comment_controller.Unresolve)
+
+		rUser.GET("/canvas/tag", canvas_tag_controller.Index)
+		rUser.DELETE("/canvas/tag/:canvas_tag_id", canvas_tag_controller.Delete)
+
+		rUser.GET("/folder", folder_controller.Index)
+		rUser.POST("/folder", folder_controller.Create)
+		rUser.GET("/folder/:folder_id", folder_controller.Get)
+		rUser.POST("/folder/:folder_id", folder_controller.Update)
+		rUser.DELETE("/folder/:folder_id", folder_controller.Delete)
+
+		rUser.GET("/canvas/:canvas_id/versions", canvas_version_controller.Index)
+		rUser.GET("/canvas/:canvas_id/versions/:version_id", canvas_version_controller.Get)
+		rUser.GET("/canvas/:canvas_id/versions/:version_id/diff", canvas_version_controller.Diff)
+		rUser.POST("/canvas/:canvas_id/versions/:version_id/restore", canvas_version_controller.Restore)
 
 		rUser.GET("/canvas/:canvas_id/accept_invite/:code", canvas_shared_invitation_controller.AcceptInvite)
 
@@ -119,6 +196,7 @@ func main() {
 		rUser.DELETE("/canvas/shared_invitation/:canvas_shared_invitation_id", canvas_shared_invitation_controller.Delete)
 		//
 		rUser.GET("/canvas/shared_access", canvas_shared_access_controller.Index)
+		rUser.POST("/canvas/shared_access/:canvas_shared_access_id", canvas_shared_access_controller.Update)
 		rUser.DELETE("/canvas/shared_access/:canvas_shared_access_id", canvas_shared_access_controller.Delete)
 		//
 		rUser.GET("/ws/canvas/:id", canvas_controller.Websocket)
@@ -9,5 +9,10 @@ import (
 func Run(c *gin.Context) {
 	c.Next()
 
+	// Handlers serving files (e.g. canvas exports) write their own body
+	if c.Writer.Written() {
+		return
+	}
+
 	response_service.Response(c)
 }
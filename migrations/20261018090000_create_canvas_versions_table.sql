@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "public"."canvas_versions"(
+    "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    "deleted_at" timestamp DEFAULT NULL,
+    "canvas_id" "uuid" NOT NULL REFERENCES "public"."canvases",
+    "user_id" "uuid" DEFAULT NULL REFERENCES "public"."users",
+    "source" varchar NOT NULL,
+    "canvas_data" "jsonb"
+);
+CREATE INDEX IF NOT EXISTS idx_canvas_versions_canvas_id_created_at ON canvas_versions (canvas_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."canvas_versions";
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing invitations and accesses granted full edit rights, so they default to editor
+ALTER TABLE "public"."canvas_shared_invitations"
+    ADD COLUMN IF NOT EXISTS "role" varchar NOT NULL DEFAULT 'editor' CHECK ("role" IN ('viewer', 'editor'));
+ALTER TABLE "public"."canvas_shared_accesses"
+    ADD COLUMN IF NOT EXISTS "role" varchar NOT NULL DEFAULT 'editor' CHECK ("role" IN ('viewer', 'editor'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."canvas_shared_accesses" DROP COLUMN IF EXISTS "role";
+ALTER TABLE "public"."canvas_shared_invitations" DROP COLUMN IF EXISTS "role";
+-- +goose StatementEnd
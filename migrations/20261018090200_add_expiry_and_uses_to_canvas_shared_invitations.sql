@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."canvas_shared_invitations"
+    ADD COLUMN IF NOT EXISTS "expires_at" timestamp DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS "max_uses" integer DEFAULT NULL CHECK ("max_uses" > 0),
+    ADD COLUMN IF NOT EXISTS "uses" integer NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."canvas_shared_invitations"
+    DROP COLUMN IF EXISTS "uses",
+    DROP COLUMN IF EXISTS "max_uses",
+    DROP COLUMN IF EXISTS "expires_at";
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "public"."canvas_thumbnails"(
+    "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    "deleted_at" timestamp DEFAULT NULL,
+    "canvas_id" "uuid" NOT NULL UNIQUE REFERENCES "public"."canvases",
+    "image" bytea NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."canvas_thumbnails";
+-- +goose StatementEnd
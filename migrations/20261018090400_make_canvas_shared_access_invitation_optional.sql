@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."canvas_shared_accesses" ALTER COLUMN "canvas_shared_invitation_id" DROP NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Accesses granted without an invitation can't be kept once one is required, refuse rather than revoking them
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM "public"."canvas_shared_accesses" WHERE "canvas_shared_invitation_id" IS NULL) THEN
+        RAISE EXCEPTION 'canvas_shared_accesses has rows without an invitation, remove or reassign them before migrating down';
+    END IF;
+END $$;
+ALTER TABLE "public"."canvas_shared_accesses" ALTER COLUMN "canvas_shared_invitation_id" SET NOT NULL;
+-- +goose StatementEnd
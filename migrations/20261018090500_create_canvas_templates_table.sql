@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "public"."canvas_templates"(
+    "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    "deleted_at" timestamp DEFAULT NULL,
+    "user_id" "uuid" DEFAULT NULL REFERENCES "public"."users",
+    "name" varchar NOT NULL,
+    "description" text NOT NULL DEFAULT '',
+    "canvas_data" "jsonb" NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_canvas_templates_user_id ON canvas_templates (user_id);
+-- +goose StatementEnd
+
+-- System templates have no user_id
+-- +goose StatementBegin
+INSERT INTO "public"."canvas_templates"(name, description, canvas_data) VALUES
+('Retro board', 'Three columns: went well (green), to improve (red) and action items (blue).', '{"name":"Retro board","backgroundColor":"#ffffff","pieceSettings":{"size":2,"color":"#000000"},"rulerSettings":{"showUnits":false,"showLines":false},"piecesManager":{"pieces":[{"id":"retro-went-well","layerId":"default","kind":"path","settings":{"size":3,"color":"#2e7d32"},"path":"M 0 0 H 400 V 800 H 0 Z","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":400,"topMost":0,"bottomMost":800},{"id":"retro-went-well-header","layerId":"default","kind":"path","settings":{"size":2,"color":"#2e7d32"},"path":"M 0 80 H 400","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":400,"topMost":80,"bottomMost":80},{"id":"retro-to-improve","layerId":"default","kind":"path","settings":{"size":3,"color":"#c62828"},"path":"M 420 0 H 820 V 800 H 420 Z","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":420,"rightMost":820,"topMost":0,"bottomMost":800},{"id":"retro-to-improve-header","layerId":"default","kind":"path","settings":{"size":2,"color":"#c62828"},"path":"M 420 80 H 820","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":420,"rightMost":820,"topMost":80,"bottomMost":80},{"id":"retro-action-items","layerId":"default","kind":"path","settings":{"size":3,"color":"#1565c0"},"path":"M 840 0 H 1240 V 800 H 840 Z","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":840,"rightMost":1240,"topMost":0,"bottomMost":800},{"id":"retro-action-items-header","layerId":"default","kind":"path","settings":{"size":2,"color":"#1565c0"},"path":"M 840 80 H 1240","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":840,"rightMost":1240,"topMost":80,"bottomMost":80}],"layers":[{"id":"default","name":"Layer 1","hidden":false,"locked":false}],"leftMost":0,"rightMost":1240,"topMost":0,"bottomMost":800}}'),
+('Architecture grid', 'A 1200 by 800 grid of 100 unit squares for laying out system diagrams.', '{"name":"Architecture grid","backgroundColor":"#ffffff","pieceSettings":{"size":2,"color":"#000000"},"rulerSettings":{"showUnits":false,"showLines":false},"piecesManager":{"pieces":[{"id":"grid-v-0","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":0,"topMost":0,"bottomMost":800},{"id":"grid-v-1","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 100 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":100,"rightMost":100,"topMost":0,"bottomMost":800},{"id":"grid-v-2","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 200 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":200,"rightMost":200,"topMost":0,"bottomMost":800},{"id":"grid-v-3","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 300 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":300,"rightMost":300,"topMost":0,"bottomMost":800},{"id":"grid-v-4","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 400 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":400,"rightMost":400,"topMost":0,"bottomMost":800},{"id":"grid-v-5","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 500 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":500,"rightMost":500,"topMost":0,"bottomMost":800},{"id":"grid-v-6","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 600 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":600,"rightMost":600,"topMost":0,"bottomMost":800},{"id":"grid-v-7","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 700 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":700,"rightMost":700,"topMost":0,"bottomMost":800},{"id":"grid-v-8","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 800 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":800,"rightMost":800,"topMost":0,"bottomMost":800},{"id":"grid-v-9","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 900 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":900,"rightMost":900,"topMost":0,"bottomMost":800},{"id":"grid-v-10","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 1000 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":1000,"rightMost":1000,"topMost":0,"bottomMost":800},{"id":"grid-v-11","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 1100 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":1100,"rightMost":1100,"topMost":0,"bottomMost":800},{"id":"grid-v-12","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 1200 0 V 800","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":1200,"rightMost":1200,"topMost":0,"bottomMost":800},{"id":"grid-h-0","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 0 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":0,"bottomMost":0},{"id":"grid-h-1","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 100 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":100,"bottomMost":100},{"id":"grid-h-2","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 200 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":200,"bottomMost":200},{"id":"grid-h-3","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 300 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":300,"bottomMost":300},{"id":"grid-h-4","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 400 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":400,"bottomMost":400},{"id":"grid-h-5","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 500 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":500,"bottomMost":500},{"id":"grid-h-6","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 600 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":600,"bottomMost":600},{"id":"grid-h-7","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 700 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":700,"bottomMost":700},{"id":"grid-h-8","layerId":"default","kind":"path","settings":{"size":1,"color":"#d0d0d0"},"path":"M 0 800 H 1200","move":{"a":1,"b":0,"c":0,"d":1,"e":0,"f":0,"m11":1,"m12":0,"m13":0,"m14":0,"m21":0,"m22":1,"m23":0,"m24":0,"m31":0,"m32":0,"m33":1,"m34":0,"m41":0,"m42":0,"m43":0,"m44":1},"leftMost":0,"rightMost":1200,"topMost":800,"bottomMost":800}],"layers":[{"id":"default","name":"Layer 1","hidden":false,"locked":false}],"leftMost":0,"rightMost":1200,"topMost":0,"bottomMost":800}}');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."canvas_templates";
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Name search uses ILIKE '%...%', which only a trigram index can serve. Creating the pg_trgm extension needs more than the
+-- migrating role usually has, so it is installed as an ops step (see the README) and the index is skipped without it
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        EXECUTE 'CREATE INDEX IF NOT EXISTS idx_canvases_name_trgm ON canvases USING gin ((canvas_data->>''name'') gin_trgm_ops) WHERE deleted_at IS NULL';
+    ELSE
+        RAISE NOTICE 'pg_trgm is not installed, skipping idx_canvases_name_trgm';
+    END IF;
+END $$;
+CREATE INDEX IF NOT EXISTS idx_canvases_user_id_updated_at ON canvases (user_id, updated_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_canvases_user_id_created_at ON canvases (user_id, created_at DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_canvases_user_id_name ON canvases (user_id, lower(canvas_data->>'name')) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_canvases_deleted_at ON canvases (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Looked up by every access check
+CREATE INDEX IF NOT EXISTS idx_canvas_shared_accesses_user_id_canvas_id ON canvas_shared_accesses (user_id, canvas_id) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_canvas_shared_accesses_user_id_canvas_id;
+DROP INDEX IF EXISTS idx_canvases_deleted_at;
+DROP INDEX IF EXISTS idx_canvases_user_id_name;
+DROP INDEX IF EXISTS idx_canvases_user_id_created_at;
+DROP INDEX IF EXISTS idx_canvases_user_id_updated_at;
+DROP INDEX IF EXISTS idx_canvases_name_trgm;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "public"."folders"(
+    "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    "deleted_at" timestamp DEFAULT NULL,
+    "user_id" "uuid" NOT NULL REFERENCES "public"."users",
+    "parent_id" "uuid" DEFAULT NULL REFERENCES "public"."folders",
+    "name" varchar NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_folders_user_id_parent_id ON folders (user_id, parent_id) WHERE deleted_at IS NULL;
+
+-- Folders belong to the canvas owner, collaborators organize shared canvases with tags
+ALTER TABLE "public"."canvases" ADD COLUMN IF NOT EXISTS "folder_id" "uuid" DEFAULT NULL REFERENCES "public"."folders";
+CREATE INDEX IF NOT EXISTS idx_canvases_folder_id ON canvases (folder_id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS "public"."canvas_tags"(
+    "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    "deleted_at" timestamp DEFAULT NULL,
+    "canvas_id" "uuid" NOT NULL REFERENCES "public"."canvases",
+    "user_id" "uuid" NOT NULL REFERENCES "public"."users",
+    "name" varchar NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_canvas_tags_canvas_id_name ON canvas_tags (canvas_id, lower(name)) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_canvas_tags_name ON canvas_tags (lower(name)) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."canvas_tags";
+ALTER TABLE "public"."canvases" DROP COLUMN IF EXISTS "folder_id";
+DROP TABLE IF EXISTS "public"."folders";
+-- +goose StatementEnd
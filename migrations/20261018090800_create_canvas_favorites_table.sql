@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "public"."canvas_favorites"(
+    "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    "deleted_at" timestamp DEFAULT NULL,
+    "user_id" "uuid" NOT NULL REFERENCES "public"."users",
+    "canvas_id" "uuid" NOT NULL REFERENCES "public"."canvases"
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_canvas_favorites_user_id_canvas_id ON canvas_favorites (user_id, canvas_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_canvas_favorites_canvas_id ON canvas_favorites (canvas_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."canvas_favorites";
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Assets outlive purged canvases with no canvas_id, so the trash job can delete their stored files before the rows
+CREATE TABLE IF NOT EXISTS "public"."assets"(
+    "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    "deleted_at" timestamp DEFAULT NULL,
+    "canvas_id" "uuid" DEFAULT NULL REFERENCES "public"."canvases" ON DELETE SET NULL,
+    "user_id" "uuid" NOT NULL REFERENCES "public"."users",
+    "storage_key" varchar NOT NULL UNIQUE,
+    "content_type" varchar NOT NULL,
+    "size" bigint NOT NULL,
+    "filename" varchar NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_assets_canvas_id ON assets (canvas_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_assets_orphaned ON assets (id) WHERE canvas_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."assets";
+-- +goose StatementEnd
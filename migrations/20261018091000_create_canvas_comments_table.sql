@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Threads start with a comment anchored to a point (x, y) or a piece, replies have a parent_id and no anchor
+CREATE TABLE IF NOT EXISTS "public"."canvas_comments"(
+    "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    "deleted_at" timestamp DEFAULT NULL,
+    "canvas_id" "uuid" NOT NULL REFERENCES "public"."canvases",
+    "user_id" "uuid" NOT NULL REFERENCES "public"."users",
+    "parent_id" "uuid" DEFAULT NULL REFERENCES "public"."canvas_comments",
+    "body" text NOT NULL,
+    "x" double precision DEFAULT NULL,
+    "y" double precision DEFAULT NULL,
+    "piece_id" varchar DEFAULT NULL,
+    "resolved_at" timestamp DEFAULT NULL,
+    "resolved_by" "uuid" DEFAULT NULL REFERENCES "public"."users"
+);
+CREATE INDEX IF NOT EXISTS idx_canvas_comments_canvas_id ON canvas_comments (canvas_id, created_at) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_canvas_comments_parent_id ON canvas_comments (parent_id) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."canvas_comments";
+-- +goose StatementEnd
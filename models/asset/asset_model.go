@@ -0,0 +1,67 @@
+package asset_model
+
+import (
+	"fmt"
+	model "qolboard-api/models"
+	"qolboard-api/services/logging"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Gets an asset of a canvas the user has access to
+func Get(tx *sqlx.Tx, canvasId string, assetId string) (*model.Asset, error) {
+	asset := &model.Asset{}
+	err := tx.Get(asset, fmt.Sprintf(`
+SELECT a.*
+FROM assets a
+JOIN canvases c ON c.id = a.canvas_id
+WHERE a.id = $1
+AND a.canvas_id = $2
+AND a.deleted_at IS NULL
+AND c.deleted_at IS NULL
+AND %s
+	`, model.SqlHasAccessToCanvas("c")), assetId, canvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error getting asset", err)
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// Assets whose canvas was purged, their stored files still need deleting
+func GetOrphaned(tx *sqlx.Tx, limit int) ([]model.Asset, error) {
+	assets := make([]model.Asset, 0)
+	err := tx.Select(&assets, `
+SELECT *
+FROM assets
+WHERE canvas_id IS NULL
+ORDER BY id
+LIMIT $1
+	`, limit)
+	if err != nil {
+		logging.LogError("[model]", "Error getting orphaned assets", err)
+		return nil, err
+	}
+
+	return assets, nil
+}
+
+// Permanently deletes asset rows, only once their stored files are gone
+func Purge(tx *sqlx.Tx, assetIds []string) error {
+	if len(assetIds) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In(`DELETE FROM assets WHERE id IN (?)`, assetIds)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(tx.Rebind(query), args...)
+	if err != nil {
+		logging.LogError("[model]", "Error purging assets", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"fmt"
+	service "qolboard-api/services"
+	"qolboard-api/services/logging"
+	relations_service "qolboard-api/services/relations"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// An uploaded file used by a canvas, e.g. by image pieces. The file itself lives in storage under StorageKey
+type Asset struct {
+	Model
+	CanvasId    *string `json:"canvas_id" db:"canvas_id"` // Nil once the canvas was purged, until the file is cleaned up
+	UserId      string  `json:"user_id" db:"user_id"`     // Who uploaded the file
+	StorageKey  string  `json:"-" db:"storage_key"`
+	ContentType string  `json:"content_type" db:"content_type"` // Sniffed from the contents, not what the client claimed
+	Size        int64   `json:"size" db:"size"`
+	Filename    string  `json:"filename" db:"filename"`
+	Canvas      *Canvas `json:"canvas"`
+	User        *User   `json:"user"`
+}
+
+var AssetRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
+
+func init() {
+	relations_service.BelongsTo(
+		"canvas",
+		AssetRelations,
+		sqlSelectCanvases+" WHERE id = $1 AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE id IN (?) AND deleted_at IS NULL",
+		func(a Asset, c Canvas) Asset { a.Canvas = &c; return a },
+		func(a Asset) any {
+			if a.CanvasId == nil {
+				return nil
+			}
+			return *a.CanvasId
+		},
+		func(c Canvas) any { return c.ID },
+	)
+
+	relations_service.BelongsTo(
+		"user",
+		AssetRelations,
+		"SELECT * FROM users WHERE id = $1",
+		"SELECT * FROM users WHERE id IN (?)",
+		func(a Asset, u User) Asset { a.User = &u; return a },
+		func(a Asset) any { return a.UserId },
+		func(u User) any { return u.Id },
+	)
+}
+
+func (a Asset) GetRelations() relations_service.RelationRegistry {
+	return AssetRelations
+}
+
+func (a Asset) GetPrimaryKey() any {
+	return a.ID
+}
+
+// Records an uploaded file on a canvas the user can edit, sql.ErrNoRows when they can't
+func (a *Asset) Insert(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(a, fmt.Sprintf(`
+INSERT INTO assets(created_at, updated_at, canvas_id, user_id, storage_key, content_type, size, filename)
+SELECT $1, $1, c.id, get_user_uuid(), $2, $3, $4, $5
+FROM canvases c
+WHERE c.id = $6
+AND c.deleted_at IS NULL
+AND %s
+RETURNING *
+	`, SqlCanEditCanvas("c")), now, a.StorageKey, a.ContentType, a.Size, a.Filename, a.CanvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error inserting asset", err)
+		return err
+	}
+
+	return nil
+}
+
+func (a Asset) Response() map[string]any {
+	return service.ToMapStringAny(a)
+}
@@ -1,9 +1,13 @@
 package canvas_model
 
 import (
+	"encoding/json"
 	"fmt"
 	model "qolboard-api/models"
+	canvas_service "qolboard-api/services/canvas"
 	"qolboard-api/services/logging"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -11,12 +15,12 @@ import (
 func Get(tx *sqlx.Tx, canvasId string) (*model.Canvas, error) {
 	canvas := &model.Canvas{}
 	err := tx.Get(canvas, fmt.Sprintf(`
-SELECT *
+SELECT %s
 FROM canvases c
 WHERE c.id = $1
 AND deleted_at IS NULL
 AND %s
-	`, model.SqlHasAccessToCanvas("c")), canvasId)
+	`, model.SqlCanvasColumns("c"), model.SqlHasAccessToCanvas("c")), canvasId)
 	if err != nil {
 		logging.LogError("[model]", "Error getting canvas", err)
 		return nil, err
@@ -25,21 +29,228 @@ AND %s
 	return canvas, nil
 }
 
-func GetAll(tx *sqlx.Tx, limit int, page int) ([]model.Canvas, error) {
+// Gets the authenticated user's role on a canvas, editor wins over viewer if they somehow have both
+func GetRole(tx *sqlx.Tx, canvasId string) (string, error) {
+	var role string
+	err := tx.Get(&role, fmt.Sprintf(`
+SELECT
+	CASE WHEN c.user_id = get_user_uuid() THEN '%s'
+	ELSE (
+		SELECT csa.role
+		FROM canvas_shared_accesses csa
+		WHERE csa.user_id = get_user_uuid()
+		AND csa.canvas_id = c.id
+		AND csa.deleted_at IS NULL
+		ORDER BY csa.role = '%s' DESC
+		LIMIT 1
+	)
+	END
+FROM canvases c
+WHERE c.id = $1
+AND deleted_at IS NULL
+AND %s
+	`, model.CanvasRoleOwner, model.CanvasRoleEditor, model.SqlHasAccessToCanvas("c")), canvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvas role", err)
+		return "", err
+	}
+
+	return role, nil
+}
+
+const (
+	OwnershipOwned  = "owned"
+	OwnershipShared = "shared"
+)
+
+const FolderNone = "none"
+
+// Narrows and orders the canvases listed by GetAll, zero values don't filter
+type Filter struct {
+	Search        string // Case insensitive match anywhere in the canvas name
+	Ownership     string // OwnershipOwned or OwnershipShared
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	FolderId      string // One of the user's folders, or FolderNone for their canvases outside any folder
+	Tag           string // Case insensitive tag name
+	Favorites     bool   // Only canvases the user pinned
+	Sort          string // "name", "created_at" or "updated_at", defaults to "updated_at"
+	Order         string // "asc" or "desc", defaults to "asc" when sorting by name and "desc" otherwise
+}
+
+var sortColumns = map[string]string{
+	"name":       "lower(c.canvas_data->>'name')",
+	"created_at": "c.created_at",
+	"updated_at": "c.updated_at",
+}
+
+// Builds the WHERE clause for the filter, its args are numbered from $1
+func (f Filter) where() (string, []any) {
+	conditions := []string{"c.deleted_at IS NULL", model.SqlHasAccessToCanvas("c")}
+	args := make([]any, 0)
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.Search != "" {
+		conditions = append(conditions, fmt.Sprintf("c.canvas_data->>'name' ILIKE '%%' || %s || '%%'", arg(escapeLike(f.Search))))
+	}
+	switch f.Ownership {
+	case OwnershipOwned:
+		conditions = append(conditions, "c.user_id = get_user_uuid()")
+	case OwnershipShared:
+		conditions = append(conditions, "c.user_id <> get_user_uuid()")
+	}
+	switch f.FolderId {
+	case "":
+	case FolderNone:
+		conditions = append(conditions, "c.user_id = get_user_uuid() AND c.folder_id IS NULL")
+	default:
+		conditions = append(conditions, fmt.Sprintf("c.user_id = get_user_uuid() AND c.folder_id = %s", arg(f.FolderId)))
+	}
+	if f.Tag != "" {
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+	SELECT ct.id
+	FROM canvas_tags ct
+	WHERE ct.canvas_id = c.id
+	AND lower(ct.name) = lower(%s)
+	AND ct.deleted_at IS NULL
+)`, arg(f.Tag)))
+	}
+	if f.Favorites {
+		conditions = append(conditions, `EXISTS (
+	SELECT cf.id
+	FROM canvas_favorites cf
+	WHERE cf.canvas_id = c.id
+	AND cf.user_id = get_user_uuid()
+	AND cf.deleted_at IS NULL
+)`)
+	}
+	if f.UpdatedAfter != nil {
+		conditions = append(conditions, fmt.Sprintf("c.updated_at >= %s", arg(*f.UpdatedAfter)))
+	}
+	if f.UpdatedBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("c.updated_at < %s", arg(*f.UpdatedBefore)))
+	}
+
+	return strings.Join(conditions, "\nAND "), args
+}
+
+func (f Filter) orderBy() string {
+	column, ok := sortColumns[f.Sort]
+	if !ok {
+		column = sortColumns["updated_at"]
+	}
+
+	order := f.Order
+	if order != "asc" && order != "desc" {
+		order = "desc"
+		if f.Sort == "name" {
+			order = "asc"
+		}
+	}
+
+	// id keeps pages stable when the sort column has ties
+	return fmt.Sprintf("%s %s, c.id %s", column, order, order)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Lists the canvases the user has access to matching the filter, along with how many match in total
+func GetAll(tx *sqlx.Tx, filter Filter, limit int, page int) ([]model.Canvas, int, error) {
+	limit = min(limit, 100)
 	offset := max(page-1, 0) * limit
+	where, args := filter.where()
+
+	var total int
+	err := tx.Get(&total, fmt.Sprintf(`
+SELECT count(*)
+FROM canvases c
+WHERE %s
+	`, where), args...)
+	if err != nil {
+		logging.LogError("[model]", "Error counting canvases", err)
+		return nil, 0, err
+	}
+
+	canvases := make([]model.Canvas, 0)
+	err = tx.Select(&canvases, fmt.Sprintf(`
+SELECT %s
+FROM canvases c
+WHERE %s
+ORDER BY %s
+LIMIT $%d
+OFFSET $%d
+	`, model.SqlCanvasColumns("c"), where, filter.orderBy(), len(args)+1, len(args)+2), append(args, limit, offset)...)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvases", err)
+		return nil, 0, err
+	}
+
+	return canvases, total, nil
+}
+
+// Lists the authenticated user's deleted canvases, most recently deleted first
+func GetAllDeleted(tx *sqlx.Tx, limit int, page int) ([]model.Canvas, error) {
 	limit = min(limit, 100)
-	var canvases []model.Canvas
+	offset := max(page-1, 0) * limit
+	canvases := make([]model.Canvas, 0)
 	err := tx.Select(&canvases, fmt.Sprintf(`
-SELECT *
+SELECT %s
 FROM canvases c
-WHERE deleted_at IS NULL
-AND %s
+WHERE deleted_at IS NOT NULL
+AND c.user_id = get_user_uuid()
+ORDER BY deleted_at DESC
 LIMIT $1
 OFFSET $2
-	`, model.SqlHasAccessToCanvas("c")), limit, offset)
+	`, model.SqlCanvasColumns("c")), limit, offset)
 	if err != nil {
+		logging.LogError("[model]", "Error getting deleted canvases", err)
 		return nil, err
 	}
 
-	return canvases, err
+	return canvases, nil
+}
+
+// Permanently deletes every canvas deleted before the cutoff, regardless of owner
+func PurgeDeletedBefore(tx *sqlx.Tx, cutoff time.Time) (int64, error) {
+	return model.PurgeCanvases(tx, "deleted_at IS NOT NULL AND deleted_at < $1", cutoff)
+}
+
+// Locks and lists the canvases after the given id, trashed ones included, regardless of owner
+func GetBatchForUpdate(tx *sqlx.Tx, afterId string, limit int) ([]model.Canvas, error) {
+	canvases := make([]model.Canvas, 0)
+	err := tx.Select(&canvases, `
+SELECT *
+FROM canvases
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+FOR UPDATE
+	`, afterId, limit)
+	if err != nil {
+		logging.LogError("[model]", "Error getting batch of canvases", err)
+		return nil, err
+	}
+
+	return canvases, nil
+}
+
+// Replaces a canvas' data without touching updated_at, for maintenance which doesn't change what the canvas looks like
+func SetCanvasData(tx *sqlx.Tx, canvasId string, canvasData canvas_service.CanvasData) error {
+	canvasDataBytes, err := json.Marshal(canvasData)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE canvases SET canvas_data = $1 WHERE id = $2", string(canvasDataBytes), canvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error setting canvas data", err)
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,64 @@
+package canvas_comment_model
+
+import (
+	"fmt"
+	model "qolboard-api/models"
+	"qolboard-api/services/logging"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Lists the comment threads on a canvas the user has access to, oldest first. A nil resolved lists both open and resolved threads
+func GetAll(tx *sqlx.Tx, canvasId string, resolved *bool, limit int, page int) ([]model.CanvasComment, error) {
+	limit = min(limit, 100)
+	offset := max(page-1, 0) * limit
+
+	resolvedFilter := ""
+	if resolved != nil && *resolved {
+		resolvedFilter = "AND cc.resolved_at IS NOT NULL"
+	} else if resolved != nil {
+		resolvedFilter = "AND cc.resolved_at IS NULL"
+	}
+
+	comments := make([]model.CanvasComment, 0)
+	err := tx.Select(&comments, fmt.Sprintf(`
+SELECT cc.*
+FROM canvas_comments cc
+JOIN canvases c ON c.id = cc.canvas_id
+WHERE cc.canvas_id = $1
+AND cc.parent_id IS NULL
+AND cc.deleted_at IS NULL
+AND c.deleted_at IS NULL
+AND %s
+%s
+ORDER BY cc.created_at, cc.id
+LIMIT $2
+OFFSET $3
+	`, model.SqlHasAccessToCanvas("c"), resolvedFilter), canvasId, limit, offset)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvas comments", err)
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Gets a comment on a canvas the user has access to
+func Get(tx *sqlx.Tx, commentId string) (*model.CanvasComment, error) {
+	comment := &model.CanvasComment{}
+	err := tx.Get(comment, fmt.Sprintf(`
+SELECT cc.*
+FROM canvas_comments cc
+JOIN canvases c ON c.id = cc.canvas_id
+WHERE cc.id = $1
+AND cc.deleted_at IS NULL
+AND c.deleted_at IS NULL
+AND %s
+	`, model.SqlHasAccessToCanvas("c")), commentId)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvas comment", err)
+		return nil, err
+	}
+
+	return comment, nil
+}
@@ -0,0 +1,226 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	service "qolboard-api/services"
+	"qolboard-api/services/logging"
+	relations_service "qolboard-api/services/relations"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Feedback on a canvas from anyone with access to it, threads start with a comment anchored to a point or a piece,
+// replies belong to the thread and have no anchor of their own
+type CanvasComment struct {
+	Model
+	CanvasId   string          `json:"canvas_id" db:"canvas_id"`
+	UserId     string          `json:"user_id" db:"user_id"`
+	ParentId   *string         `json:"parent_id" db:"parent_id"` // The thread's first comment, nil for the first comment itself
+	Body       string          `json:"body" db:"body"`
+	X          *float64        `json:"x" db:"x"` // Canvas coordinates, when anchored to a point
+	Y          *float64        `json:"y" db:"y"`
+	PieceId    *string         `json:"piece_id" db:"piece_id"` // When anchored to a piece, which may since have been removed
+	ResolvedAt *time.Time      `json:"resolved_at" db:"resolved_at"`
+	ResolvedBy *string         `json:"resolved_by" db:"resolved_by"`
+	Canvas     *Canvas         `json:"canvas"`
+	User       *User           `json:"user"`
+	Parent     *CanvasComment  `json:"parent"`
+	Replies    []CanvasComment `json:"replies"`
+}
+
+var ErrCanvasCommentThreadNotFound = errors.New("comment thread not found")
+
+var CanvasCommentRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
+
+func init() {
+	relations_service.BelongsTo(
+		"canvas",
+		CanvasCommentRelations,
+		sqlSelectCanvases+" WHERE id = $1 AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE id IN (?) AND deleted_at IS NULL",
+		func(cc CanvasComment, c Canvas) CanvasComment { cc.Canvas = &c; return cc },
+		func(cc CanvasComment) any { return cc.CanvasId },
+		func(c Canvas) any { return c.ID },
+	)
+
+	relations_service.BelongsTo(
+		"user",
+		CanvasCommentRelations,
+		"SELECT * FROM users WHERE id = $1",
+		"SELECT * FROM users WHERE id IN (?)",
+		func(cc CanvasComment, u User) CanvasComment { cc.User = &u; return cc },
+		func(cc CanvasComment) any { return cc.UserId },
+		func(u User) any { return u.Id },
+	)
+
+	relations_service.BelongsTo(
+		"parent",
+		CanvasCommentRelations,
+		"SELECT * FROM canvas_comments WHERE id = $1 AND deleted_at IS NULL",
+		"SELECT * FROM canvas_comments WHERE id IN (?) AND deleted_at IS NULL",
+		func(cc CanvasComment, p CanvasComment) CanvasComment { cc.Parent = &p; return cc },
+		func(cc CanvasComment) any {
+			if cc.ParentId == nil {
+				return nil
+			}
+			return *cc.ParentId
+		},
+		func(p CanvasComment) any { return p.ID },
+	)
+
+	relations_service.HasMany(
+		"replies",
+		CanvasCommentRelations,
+		"SELECT * FROM canvas_comments WHERE parent_id = $1 AND deleted_at IS NULL ORDER BY created_at, id",
+		"SELECT * FROM canvas_comments WHERE parent_id IN (?) AND deleted_at IS NULL ORDER BY created_at, id",
+		func(cc CanvasComment, replies []CanvasComment) CanvasComment { cc.Replies = replies; return cc },
+		func(cc CanvasComment) any { return cc.ID },
+		func(reply CanvasComment) any {
+			if reply.ParentId == nil {
+				return nil
+			}
+			return *reply.ParentId
+		},
+	)
+}
+
+func (cc CanvasComment) GetRelations() relations_service.RelationRegistry {
+	return CanvasCommentRelations
+}
+
+func (cc CanvasComment) GetPrimaryKey() any {
+	return cc.ID
+}
+
+// Comments on a canvas the user has access to, viewers included. Replies must be to a thread on the same canvas
+func (cc *CanvasComment) Insert(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	if cc.ParentId != nil {
+		var id string
+		err := tx.Get(&id, `
+SELECT id
+FROM canvas_comments
+WHERE id = $1
+AND canvas_id = $2
+AND parent_id IS NULL
+AND deleted_at IS NULL
+		`, *cc.ParentId, cc.CanvasId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCanvasCommentThreadNotFound
+		}
+		if err != nil {
+			logging.LogError("[model]", "Error checking comment thread", err)
+			return err
+		}
+	}
+
+	err := tx.Get(cc, fmt.Sprintf(`
+INSERT INTO canvas_comments(created_at, updated_at, canvas_id, user_id, parent_id, body, x, y, piece_id)
+SELECT $1, $1, c.id, get_user_uuid(), $2, $3, $4, $5, $6
+FROM canvases c
+WHERE c.id = $7
+AND c.deleted_at IS NULL
+AND %s
+RETURNING *
+	`, SqlHasAccessToCanvas("c")), now, cc.ParentId, cc.Body, cc.X, cc.Y, cc.PieceId, cc.CanvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error inserting canvas comment", err)
+		return err
+	}
+
+	return nil
+}
+
+// Edits the body of one of the user's own comments
+func (cc *CanvasComment) Update(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(cc, fmt.Sprintf(`
+UPDATE canvas_comments cc
+SET body = $1, updated_at = $2
+FROM canvases c
+WHERE cc.id = $3
+AND cc.user_id = get_user_uuid()
+AND cc.deleted_at IS NULL
+AND c.id = cc.canvas_id
+AND c.deleted_at IS NULL
+AND %s
+RETURNING cc.*
+	`, SqlHasAccessToCanvas("c")), cc.Body, now, cc.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error updating canvas comment", err)
+		return err
+	}
+
+	return nil
+}
+
+// Resolves or reopens a thread, anyone with access to the canvas may do either
+func (cc *CanvasComment) SetResolved(tx *sqlx.Tx, resolved bool) error {
+	now := time.Now()
+
+	var resolvedAt *time.Time
+	if resolved {
+		resolvedAt = &now
+	}
+
+	err := tx.Get(cc, fmt.Sprintf(`
+UPDATE canvas_comments cc
+SET resolved_at = $1::timestamp,
+	resolved_by = CASE WHEN $1::timestamp IS NULL THEN NULL ELSE get_user_uuid() END,
+	updated_at = $2
+FROM canvases c
+WHERE cc.id = $3
+AND cc.parent_id IS NULL
+AND cc.deleted_at IS NULL
+AND c.id = cc.canvas_id
+AND c.deleted_at IS NULL
+AND %s
+RETURNING cc.*
+	`, SqlHasAccessToCanvas("c")), resolvedAt, now, cc.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error resolving canvas comment", err)
+		return err
+	}
+
+	return nil
+}
+
+// Deletes one of the user's own comments, canvas owners may delete any comment on their canvas. Deleting the first comment
+// of a thread deletes its replies too
+func (cc *CanvasComment) Delete(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(cc, fmt.Sprintf(`
+UPDATE canvas_comments cc
+SET deleted_at = $1, updated_at = $1
+FROM canvases c
+WHERE cc.id = $2
+AND cc.deleted_at IS NULL
+AND c.id = cc.canvas_id
+AND c.deleted_at IS NULL
+AND (cc.user_id = get_user_uuid() OR c.user_id = get_user_uuid())
+AND %s
+RETURNING cc.*
+	`, SqlHasAccessToCanvas("c")), now, cc.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error deleting canvas comment", err)
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE canvas_comments SET deleted_at = $1, updated_at = $1 WHERE parent_id = $2 AND deleted_at IS NULL", now, cc.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error deleting replies of deleted canvas comment", err)
+		return err
+	}
+
+	return nil
+}
+
+func (cc CanvasComment) Response() map[string]any {
+	return service.ToMapStringAny(cc)
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"fmt"
+	service "qolboard-api/services"
+	"qolboard-api/services/logging"
+	relations_service "qolboard-api/services/relations"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// A canvas pinned by a user, owners and collaborators alike
+type CanvasFavorite struct {
+	Model
+	UserId   string  `json:"user_id" db:"user_id"`
+	CanvasId string  `json:"canvas_id" db:"canvas_id"`
+	Canvas   *Canvas `json:"canvas"`
+	User     *User   `json:"user"`
+}
+
+var CanvasFavoriteRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
+
+func init() {
+	// Favorites outlive the access they were pinned with, e.g. when it is revoked, so the canvas is only loaded while the user still has access
+	relations_service.BelongsTo(
+		"canvas",
+		CanvasFavoriteRelations,
+		sqlSelectCanvases+" WHERE id = $1 AND deleted_at IS NULL AND "+SqlHasAccessToCanvas("canvases"),
+		sqlSelectCanvases+" WHERE id IN (?) AND deleted_at IS NULL AND "+SqlHasAccessToCanvas("canvases"),
+		func(cf CanvasFavorite, c Canvas) CanvasFavorite { cf.Canvas = &c; return cf },
+		func(cf CanvasFavorite) any { return cf.CanvasId },
+		func(c Canvas) any { return c.ID },
+	)
+
+	relations_service.BelongsTo(
+		"user",
+		CanvasFavoriteRelations,
+		"SELECT * FROM users WHERE id = $1",
+		"SELECT * FROM users WHERE id IN (?)",
+		func(cf CanvasFavorite, u User) CanvasFavorite { cf.User = &u; return cf },
+		func(cf CanvasFavorite) any { return cf.UserId },
+		func(u User) any { return u.Id },
+	)
+}
+
+func (cf CanvasFavorite) GetRelations() relations_service.RelationRegistry {
+	return CanvasFavoriteRelations
+}
+
+func (cf CanvasFavorite) GetPrimaryKey() any {
+	return cf.ID
+}
+
+// Pins a canvas the user has access to, pinning it again returns the existing favorite
+func (cf *CanvasFavorite) Insert(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(cf, fmt.Sprintf(`
+WITH inserted AS (
+	INSERT INTO canvas_favorites(created_at, updated_at, user_id, canvas_id)
+	SELECT $1, $1, get_user_uuid(), c.id
+	FROM canvases c
+	WHERE c.id = $2
+	AND c.deleted_at IS NULL
+	AND %s
+	ON CONFLICT (user_id, canvas_id) WHERE deleted_at IS NULL DO NOTHING
+	RETURNING *
+)
+SELECT * FROM inserted
+UNION ALL
+SELECT * FROM canvas_favorites
+WHERE user_id = get_user_uuid()
+AND canvas_id = $2
+AND deleted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM inserted)
+	`, SqlHasAccessToCanvas("c")), now, cf.CanvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error inserting canvas favorite", err)
+		return err
+	}
+
+	return nil
+}
+
+// Unpins one of the user's favorite canvases
+func (cf *CanvasFavorite) Delete(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(cf, "UPDATE canvas_favorites SET deleted_at = $1, updated_at = $1 WHERE canvas_id = $2 AND user_id = get_user_uuid() AND deleted_at IS NULL RETURNING *", now, cf.CanvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error deleting canvas favorite", err)
+		return err
+	}
+
+	return nil
+}
+
+func (cf CanvasFavorite) Response() map[string]any {
+	return service.ToMapStringAny(cf)
+}
@@ -12,17 +12,32 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+const (
+	CanvasRoleOwner  = "owner"
+	CanvasRoleEditor = "editor"
+	CanvasRoleViewer = "viewer"
+)
+
 type Canvas struct {
 	Model
 	UserId                  string                    `json:"user_id" db:"user_id"`
+	FolderId                *string                   `json:"folder_id" db:"folder_id"` // One of the owner's folders, NULL for the top level
 	CanvasData              canvas_service.CanvasData `json:"canvas_data" db:"canvas_data"`
 	CanvasSharedAccesses    []CanvasSharedAccess      `json:"canvas_shared_accesses"`
 	CanvasSharedInvitations []CanvasSharedInvitation  `json:"canvas_shared_invitations"`
 	User                    *User                     `json:"user"`
+	Thumbnail               *CanvasThumbnail          `json:"thumbnail"`
+	Folder                  *Folder                   `json:"folder"`
+	Tags                    []CanvasTag               `json:"tags"`
+	Comments                []CanvasComment           `json:"comments"`           // Threads, with their replies loaded through comments.replies
+	IsFavorite              bool                      `json:"-" db:"is_favorite"` // Only selected along with SqlCanvasColumns
 }
 
 var CanvasRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
 
+// Selects canvases along with whether the authenticated user pinned them, for relations loading canvases
+var sqlSelectCanvases = fmt.Sprintf("SELECT %s FROM canvases", SqlCanvasColumns("canvases"))
+
 func (c Canvas) GetRelations() relations_service.RelationRegistry {
 	return CanvasRelations
 }
@@ -43,17 +58,63 @@ func init() {
 		func(u User) any { return u.Id },
 	)
 
-	// Has many CanvasSharedInvitations
+	// Has many CanvasSharedInvitations, only the owner sees the codes, anyone holding one can join the canvas with its role
 	relations_service.HasMany(
 		"canvas_shared_invitations",
 		CanvasRelations,
-		"SELECT * FROM canvas_shared_invitations WHERE canvas_id = $1 AND deleted_at IS NULL",
-		"SELECT * FROM canvas_shared_invitations WHERE canvas_id IN (?) AND deleted_at IS NULL",
+		"SELECT csi.* FROM canvas_shared_invitations csi JOIN canvases c ON c.id = csi.canvas_id AND c.user_id = get_user_uuid() WHERE csi.canvas_id = $1 AND csi.deleted_at IS NULL",
+		"SELECT csi.* FROM canvas_shared_invitations csi JOIN canvases c ON c.id = csi.canvas_id AND c.user_id = get_user_uuid() WHERE csi.canvas_id IN (?) AND csi.deleted_at IS NULL",
 		func(c Canvas, csi []CanvasSharedInvitation) Canvas { c.CanvasSharedInvitations = csi; return c },
 		func(c Canvas) any { return c.ID },
 		func(csi CanvasSharedInvitation) any { return csi.CanvasId },
 	)
 
+	relations_service.HasOne(
+		"thumbnail",
+		CanvasRelations,
+		"SELECT * FROM canvas_thumbnails WHERE canvas_id = $1 AND deleted_at IS NULL",
+		"SELECT * FROM canvas_thumbnails WHERE canvas_id IN (?) AND deleted_at IS NULL",
+		func(c Canvas, ct CanvasThumbnail) Canvas { c.Thumbnail = &ct; return c },
+		func(c Canvas) any { return c.ID },
+		func(ct CanvasThumbnail) any { return ct.CanvasId },
+	)
+
+	relations_service.BelongsTo(
+		"folder",
+		CanvasRelations,
+		// Only the owner sees which of their folders a canvas is in
+		"SELECT * FROM folders WHERE id = $1 AND user_id = get_user_uuid() AND deleted_at IS NULL",
+		"SELECT * FROM folders WHERE id IN (?) AND user_id = get_user_uuid() AND deleted_at IS NULL",
+		func(c Canvas, f Folder) Canvas { c.Folder = &f; return c },
+		func(c Canvas) any {
+			if c.FolderId == nil {
+				return nil
+			}
+			return *c.FolderId
+		},
+		func(f Folder) any { return f.ID },
+	)
+
+	relations_service.HasMany(
+		"tags",
+		CanvasRelations,
+		"SELECT * FROM canvas_tags WHERE canvas_id = $1 AND deleted_at IS NULL ORDER BY lower(name)",
+		"SELECT * FROM canvas_tags WHERE canvas_id IN (?) AND deleted_at IS NULL ORDER BY lower(name)",
+		func(c Canvas, ct []CanvasTag) Canvas { c.Tags = ct; return c },
+		func(c Canvas) any { return c.ID },
+		func(ct CanvasTag) any { return ct.CanvasId },
+	)
+
+	relations_service.HasMany(
+		"comments",
+		CanvasRelations,
+		"SELECT * FROM canvas_comments WHERE canvas_id = $1 AND parent_id IS NULL AND deleted_at IS NULL ORDER BY created_at, id",
+		"SELECT * FROM canvas_comments WHERE canvas_id IN (?) AND parent_id IS NULL AND deleted_at IS NULL ORDER BY created_at, id",
+		func(c Canvas, cc []CanvasComment) Canvas { c.Comments = cc; return c },
+		func(c Canvas) any { return c.ID },
+		func(cc CanvasComment) any { return cc.CanvasId },
+	)
+
 	relations_service.HasMany(
 		"canvas_shared_accesses",
 		CanvasRelations,
@@ -89,6 +150,13 @@ RETURNING *
 
 func (c *Canvas) Save(tx *sqlx.Tx) error {
 	now := time.Now()
+
+	c.CanvasData.BackfillLayers()
+	err := c.CanvasData.BackfillPieceIDs()
+	if err != nil {
+		return err
+	}
+
 	canvasDataBytes, err := json.Marshal(c.CanvasData)
 	if err != nil {
 		return err
@@ -101,10 +169,10 @@ SET canvas_data = $1, updated_at = $2
 WHERE %s
 AND id = $3
 AND deleted_at IS NULL
-RETURNING *
-		`, SqlHasAccessToCanvas("c")), string(canvasDataBytes), now, c.ID)
+RETURNING %s
+		`, SqlCanEditCanvas("c"), SqlCanvasColumns("c")), string(canvasDataBytes), now, c.ID)
 	} else {
-		err = tx.Get(c, "INSERT INTO canvases(canvas_data, created_at, updated_at, user_id) VALUES($1, $2, $3, get_user_uuid()) RETURNING *", string(canvasDataBytes), now, now)
+		err = tx.Get(c, "INSERT INTO canvases AS c(canvas_data, created_at, updated_at, user_id) VALUES($1, $2, $3, get_user_uuid()) RETURNING "+SqlCanvasColumns("c"), string(canvasDataBytes), now, now)
 	}
 
 	if err != nil {
@@ -139,11 +207,107 @@ func (c *Canvas) Delete(tx *sqlx.Tx) error {
 	return err
 }
 
+// Moves one of the user's own canvases into one of their folders, or to the top level when folderId is nil
+func (c *Canvas) MoveToFolder(tx *sqlx.Tx, folderId *string) error {
+	err := checkFolderOwned(tx, folderId)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Get(c, "UPDATE canvases SET folder_id = $1 WHERE id = $2 AND user_id = get_user_uuid() AND deleted_at IS NULL RETURNING "+SqlCanvasColumns("canvases"), folderId, c.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error moving canvas to folder", err)
+		return err
+	}
+
+	return nil
+}
+
+// Restores a canvas from the trash, along with the invitations and accesses which were deleted with it
+func (c *Canvas) Restore(tx *sqlx.Tx) error {
+	var deletedAt time.Time
+	err := tx.Get(&deletedAt, "SELECT deleted_at FROM canvases WHERE id = $1 AND user_id = get_user_uuid() AND deleted_at IS NOT NULL", c.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error finding deleted canvas", err)
+		return err
+	}
+
+	err = tx.Get(c, "UPDATE canvases SET deleted_at = NULL, updated_at = $1 WHERE id = $2 RETURNING "+SqlCanvasColumns("canvases"), time.Now(), c.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error restoring canvas", err)
+		return err
+	}
+
+	// Delete stamps the canvas and its cascaded rows with the same time, rows deleted before that stay deleted
+	_, err = tx.Exec("UPDATE canvas_shared_invitations SET deleted_at = NULL WHERE canvas_id = $1 AND deleted_at = $2", c.ID, deletedAt)
+	if err != nil {
+		logging.LogError("[model]", "Error restoring related canvas shared invitations", err)
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE canvas_shared_accesses SET deleted_at = NULL WHERE canvas_id = $1 AND deleted_at = $2", c.ID, deletedAt)
+	if err != nil {
+		logging.LogError("[model]", "Error restoring related canvas shared access", err)
+		return err
+	}
+
+	return nil
+}
+
+// Permanently deletes a canvas which is already in the owner's trash
+func (c *Canvas) Purge(tx *sqlx.Tx) error {
+	err := tx.Get(c, "SELECT * FROM canvases WHERE id = $1 AND user_id = get_user_uuid() AND deleted_at IS NOT NULL", c.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error finding deleted canvas", err)
+		return err
+	}
+
+	_, err = PurgeCanvases(tx, "id = $1", c.ID)
+	return err
+}
+
+// Permanently deletes the canvases matching the where clause along with every row referencing them, returns how many canvases were deleted
+func PurgeCanvases(tx *sqlx.Tx, where string, args ...any) (int64, error) {
+	canvasIds := fmt.Sprintf("SELECT id FROM canvases WHERE %s", where)
+
+	// Accesses reference invitations, so they go first
+	for _, table := range []string{"canvas_shared_accesses", "canvas_shared_invitations", "canvas_versions", "canvas_thumbnails", "canvas_tags", "canvas_favorites", "canvas_comments"} {
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE canvas_id IN (%s)", table, canvasIds), args...)
+		if err != nil {
+			logging.LogError("[model]", fmt.Sprintf("Error purging related %s", table), err)
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM canvases WHERE %s", where), args...)
+	if err != nil {
+		logging.LogError("[model]", "Error purging canvases", err)
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 func (c Canvas) Response() map[string]any {
 	r := service.ToMapStringAny(c)
+	if c.Thumbnail != nil {
+		r["thumbnail"] = c.Thumbnail.Response()
+	}
+	r["is_favorite"] = c.IsFavorite
 	return r
 }
 
+// The canvas' columns plus is_favorite, whether the authenticated user pinned the canvas
+func SqlCanvasColumns(aliasCanvas string) string {
+	return fmt.Sprintf(`%s.*, EXISTS (
+	SELECT cf.id
+	FROM canvas_favorites cf
+	WHERE cf.canvas_id = %s.id
+	AND cf.user_id = get_user_uuid()
+	AND cf.deleted_at IS NULL
+) AS is_favorite`, aliasCanvas, aliasCanvas)
+}
+
 func SqlHasAccessToCanvas(aliasCanvas string) string {
 	sql := fmt.Sprintf(`
 (
@@ -160,3 +324,22 @@ func SqlHasAccessToCanvas(aliasCanvas string) string {
 
 	return sql
 }
+
+// Like SqlHasAccessToCanvas, but shared access must also have the editor role
+func SqlCanEditCanvas(aliasCanvas string) string {
+	sql := fmt.Sprintf(`
+(
+	%s.user_id = get_user_uuid()
+	OR EXISTS (
+		SELECT csa.id
+		FROM canvas_shared_accesses csa
+		WHERE csa.user_id = get_user_uuid()
+		AND csa.canvas_id = %s.id
+		AND csa.role = '%s'
+		AND csa.deleted_at IS NULL
+	)
+)
+	`, aliasCanvas, aliasCanvas, CanvasRoleEditor)
+
+	return sql
+}
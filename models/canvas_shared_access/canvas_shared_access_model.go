@@ -3,6 +3,7 @@ package canvas_shared_access_model
 import (
 	model "qolboard-api/models"
 	"qolboard-api/services/logging"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -33,3 +34,43 @@ OFFSET $2
 	logging.LogDebug("[model]", "csa", csa)
 	return csa, err
 }
+
+// The authenticated user's active access to the canvas
+func GetForCanvas(tx *sqlx.Tx, canvasId string) (model.CanvasSharedAccess, error) {
+	csa := model.CanvasSharedAccess{}
+	err := tx.Get(&csa, `
+SELECT *
+FROM canvas_shared_accesses
+WHERE canvas_id = $1
+AND user_id = get_user_uuid()
+AND deleted_at IS NULL
+ORDER BY created_at
+LIMIT 1
+	`, canvasId)
+
+	return csa, err
+}
+
+// Gives everyone the from canvas is shared with the same access to the to canvas, only the owner of both may do this
+func CopySharedAccesses(tx *sqlx.Tx, fromCanvasId string, toCanvasId string) error {
+	now := time.Now()
+
+	_, err := tx.Exec(`
+INSERT INTO canvas_shared_accesses(created_at, updated_at, user_id, canvas_id, canvas_shared_invitation_id, role)
+SELECT $1, $1, csa.user_id, $3, NULL, csa.role
+FROM canvas_shared_accesses csa
+JOIN canvases from_canvas ON from_canvas.id = csa.canvas_id
+JOIN canvases to_canvas ON to_canvas.id = $3
+WHERE csa.canvas_id = $2
+AND csa.deleted_at IS NULL
+AND from_canvas.user_id = get_user_uuid()
+AND to_canvas.user_id = get_user_uuid()
+AND csa.user_id <> get_user_uuid()
+	`, now, fromCanvasId, toCanvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error copying canvas shared accesses", err)
+		return err
+	}
+
+	return nil
+}
@@ -13,7 +13,8 @@ type CanvasSharedAccess struct {
 	Model
 	UserId                   string                  `json:"user_id" db:"user_id"`
 	CanvasId                 string                  `json:"canvas_id" db:"canvas_id"`
-	CanvasSharedInvitationId string                  `json:"canvas_shared_invitation_id" db:"canvas_shared_invitation_id"`
+	CanvasSharedInvitationId *string                 `json:"canvas_shared_invitation_id" db:"canvas_shared_invitation_id"` // NULL when copied from another canvas
+	Role                     string                  `json:"role" db:"role"`
 	Canvas                   *Canvas                 `json:"canvas"`
 	CanvasSharedInvitation   *CanvasSharedInvitation `json:"canvas_shared_invitation"`
 	User                     *User                   `json:"user"`
@@ -41,8 +42,8 @@ func init() {
 	relations_service.BelongsTo(
 		"canvas",
 		CanvasSharedAccessRelations,
-		"SELECT * FROM canvases WHERE id = $1 AND deleted_at IS NULL",
-		"SELECT * FROM canvases WHERE id IN (?) AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE id = $1 AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE id IN (?) AND deleted_at IS NULL",
 		func(csa CanvasSharedAccess, c Canvas) CanvasSharedAccess {
 			csa.Canvas = &c
 			return csa
@@ -64,7 +65,10 @@ func init() {
 			return csa
 		},
 		func(csa CanvasSharedAccess) any {
-			return csa.CanvasSharedInvitationId
+			if csa.CanvasSharedInvitationId == nil {
+				return nil
+			}
+			return *csa.CanvasSharedInvitationId
 		},
 		func(csi CanvasSharedInvitation) any {
 			return csi.ID
@@ -84,9 +88,9 @@ func (csa *CanvasSharedAccess) Insert(tx *sqlx.Tx) error {
 	now := time.Now()
 
 	err := tx.Get(csa, `
-INSERT INTO canvas_shared_accesses(created_at, updated_at, user_id, canvas_id, canvas_shared_invitation_id)
-VALUES($1, $2, get_user_uuid(), $3, $4) RETURNING *
-	`, now, now, csa.CanvasId, csa.CanvasSharedInvitationId)
+INSERT INTO canvas_shared_accesses(created_at, updated_at, user_id, canvas_id, canvas_shared_invitation_id, role)
+VALUES($1, $2, get_user_uuid(), $3, $4, $5) RETURNING *
+	`, now, now, csa.CanvasId, csa.CanvasSharedInvitationId, csa.Role)
 	if err != nil {
 		return err
 	}
@@ -94,6 +98,26 @@ VALUES($1, $2, get_user_uuid(), $3, $4) RETURNING *
 	return nil
 }
 
+// Only the canvas owner may change a collaborator's role
+func (csa *CanvasSharedAccess) UpdateRole(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(csa, `
+UPDATE canvas_shared_accesses
+SET role = $1, updated_at = $2
+WHERE id = $3
+AND deleted_at IS NULL
+AND (SELECT canvases.user_id FROM canvases WHERE canvases.id = canvas_shared_accesses.canvas_id) = get_user_uuid()
+RETURNING *
+`,
+		csa.Role, now, csa.ID,
+	)
+	if err != nil {
+		logging.LogError("[model]", "Error updating canvas shared access role", err)
+	}
+	return err
+}
+
 func (csa *CanvasSharedAccess) Delete(tx *sqlx.Tx) error {
 	now := time.Now()
 
@@ -110,6 +134,13 @@ AND (
 	)
 	if err != nil {
 		logging.LogError("[model]", "Error deleting canvas shared access", err)
+		return err
+	}
+
+	// Without access the canvas is no longer the collaborator's to pin
+	_, err = tx.Exec("UPDATE canvas_favorites SET deleted_at = $1, updated_at = $1 WHERE user_id = $2 AND canvas_id = $3 AND deleted_at IS NULL", now, csa.UserId, csa.CanvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error deleting favorite of canvas shared access", err)
 	}
 	return err
 }
@@ -1,25 +1,33 @@
 package canvas_shared_invitation_model
 
 import (
+	"database/sql"
+	"errors"
 	model "qolboard-api/models"
+	canvas_shared_access_model "qolboard-api/models/canvas_shared_access"
 	service "qolboard-api/services"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
-func NewCanvasSharedInvitation(userId string, canvasId string) (*model.CanvasSharedInvitation, error) {
+func NewCanvasSharedInvitation(userId string, canvasId string, role string, expiresAt *time.Time, maxUses *int) (*model.CanvasSharedInvitation, error) {
 	code, err := service.GenerateCode(256)
 	if err != nil {
 		return nil, err
 	}
 
 	return &model.CanvasSharedInvitation{
-		UserId:   userId,
-		CanvasId: canvasId,
-		Code:     code,
+		UserId:    userId,
+		CanvasId:  canvasId,
+		Code:      code,
+		Role:      role,
+		ExpiresAt: expiresAt,
+		MaxUses:   maxUses,
 	}, nil
 }
 
+// Also finds revoked (deleted) invitations, use CheckUsable before accepting one
 func GetByCode(tx *sqlx.Tx, canvasId string, code string) (model.CanvasSharedInvitation, error) {
 	csi := model.CanvasSharedInvitation{}
 	err := tx.Get(&csi, `
@@ -32,12 +40,55 @@ AND csi.code = $2
 	return csi, err
 }
 
+// Only the canvas owner gets the invitations, for anyone else there are none
 func GetAllForCanvas(tx *sqlx.Tx, canvasId string) ([]model.CanvasSharedInvitation, error) {
 	var canvasSharedInvitiations []model.CanvasSharedInvitation
-	err := tx.Select(&canvasSharedInvitiations, "SELECT * FROM canvas_shared_invitations csi WHERE canvas_id = $1 AND deleted_at IS NULL", canvasId)
+	err := tx.Select(&canvasSharedInvitiations, `
+SELECT csi.*
+FROM canvas_shared_invitations csi
+JOIN canvases c ON c.id = csi.canvas_id AND c.user_id = get_user_uuid()
+WHERE csi.canvas_id = $1
+AND csi.deleted_at IS NULL
+	`, canvasId)
 	if err != nil {
 		return nil, err
 	}
 
 	return canvasSharedInvitiations, err
 }
+
+// Gives the authenticated user access to the invitation's canvas, counting it as a use of the invitation.
+// Users who already have access keep the access they have, accepting again uses nothing up
+func Accept(tx *sqlx.Tx, csi *model.CanvasSharedInvitation, now time.Time) (*model.CanvasSharedAccess, error) {
+	existing, err := canvas_shared_access_model.GetForCanvas(tx, csi.CanvasId)
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	err = csi.CheckUsable(now)
+	if err != nil {
+		return nil, err
+	}
+	err = csi.IncrementUses(tx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrInvitationExhausted // Lost a race for the last use
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	csa := &model.CanvasSharedAccess{
+		CanvasId:                 csi.CanvasId,
+		CanvasSharedInvitationId: &csi.ID,
+		Role:                     csi.Role,
+	}
+	err = csa.Insert(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return csa, nil
+}
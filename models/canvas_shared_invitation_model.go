@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	relations_service "qolboard-api/services/relations"
@@ -15,21 +16,32 @@ type CanvasSharedInvitation struct {
 	Code                 string               `json:"-" db:"code"`
 	CanvasId             string               `json:"canvas_id" db:"canvas_id"`
 	UserId               string               `json:"user_id" db:"user_id"`
+	Role                 string               `json:"role" db:"role"` // Role granted to whoever accepts the invitation
+	ExpiresAt            *time.Time           `json:"expires_at" db:"expires_at"`
+	MaxUses              *int                 `json:"max_uses" db:"max_uses"`
+	Uses                 int                  `json:"uses" db:"uses"`
 	Canvas               *Canvas              `json:"canvas"`
 	User                 *User                `json:"user"`
 	CanvasSharedAccesses []CanvasSharedAccess `json:"canvas_shared_access"`
 
-	InviteLink string `json:"link" gorm:"-"` // Calculated on the fly
+	InviteLink    string `json:"link" gorm:"-"`         // Calculated on the fly
+	RemainingUses *int   `json:"remaining_uses" db:"-"` // Calculated on the fly, nil when uses are unlimited
 }
 
+var (
+	ErrInvitationRevoked   = errors.New("invitation revoked")
+	ErrInvitationExpired   = errors.New("invitation expired")
+	ErrInvitationExhausted = errors.New("invitation exhausted")
+)
+
 var CanvasSharedInvitationRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
 
 func init() {
 	relations_service.BelongsTo(
 		"canvas",
 		CanvasSharedInvitationRelations,
-		"SELECT * FROM canvases WHERE id = $1 AND deleted_at IS NULL",
-		"SELECT * FROM canvases WHERE id IN (?) AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE id = $1 AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE id IN (?) AND deleted_at IS NULL",
 		func(csi CanvasSharedInvitation, c Canvas) CanvasSharedInvitation {
 			csi.Canvas = &c
 			return csi
@@ -64,7 +76,10 @@ func init() {
 			return csi.ID
 		},
 		func(csa CanvasSharedAccess) any {
-			return csa.CanvasSharedInvitationId
+			if csa.CanvasSharedInvitationId == nil {
+				return nil
+			}
+			return *csa.CanvasSharedInvitationId
 		},
 	)
 }
@@ -84,7 +99,16 @@ func (csi *CanvasSharedInvitation) Save(tx *sqlx.Tx) error {
 	if csi.ID != "" {
 		err = tx.Get(csi, "UPDATE canvas_shared_invitations SET updated_at = $1 WHERE user_id = $2 AND id = $3 AND deleted_at IS NULL RETURNING *", now, csi.UserId, csi.ID)
 	} else {
-		err = tx.Get(csi, "INSERT INTO canvas_shared_invitations(code, user_id, canvas_id, created_at, updated_at) VALUES($1, $2, $3, $4, $5) RETURNING *", csi.Code, csi.UserId, csi.CanvasId, now, now)
+		// Only the canvas owner may invite others
+		err = tx.Get(csi, `
+INSERT INTO canvas_shared_invitations(code, user_id, canvas_id, role, expires_at, max_uses, created_at, updated_at)
+SELECT $1, c.user_id, c.id, $4, $5, $6, $7, $8
+FROM canvases c
+WHERE c.id = $3
+AND c.user_id = $2
+AND c.deleted_at IS NULL
+RETURNING *
+		`, csi.Code, csi.UserId, csi.CanvasId, csi.Role, csi.ExpiresAt, csi.MaxUses, now, now)
 	}
 
 	if err != nil {
@@ -94,6 +118,36 @@ func (csi *CanvasSharedInvitation) Save(tx *sqlx.Tx) error {
 	return nil
 }
 
+// Returns why the invitation can no longer be accepted, or nil if it still can be
+func (csi CanvasSharedInvitation) CheckUsable(now time.Time) error {
+	if csi.DeletedAt != nil {
+		return ErrInvitationRevoked
+	}
+	if csi.ExpiresAt != nil && !now.Before(*csi.ExpiresAt) {
+		return ErrInvitationExpired
+	}
+	if csi.MaxUses != nil && csi.Uses >= *csi.MaxUses {
+		return ErrInvitationExhausted
+	}
+	return nil
+}
+
+// Counts an accept against the invitation, the usable checks are repeated so concurrent accepts cannot exceed max uses
+func (csi *CanvasSharedInvitation) IncrementUses(tx *sqlx.Tx) error {
+	now := time.Now()
+	err := tx.Get(csi, `
+UPDATE canvas_shared_invitations
+SET uses = uses + 1, updated_at = $1
+WHERE id = $2
+AND deleted_at IS NULL
+AND (expires_at IS NULL OR expires_at > $1)
+AND (max_uses IS NULL OR uses < max_uses)
+RETURNING *
+	`, now, csi.ID)
+
+	return err
+}
+
 func (csi *CanvasSharedInvitation) Delete(tx *sqlx.Tx) error {
 	now := time.Now()
 	err := tx.Get(csi, "UPDATE canvas_shared_invitations SET deleted_at = $1 WHERE id = $2 AND user_id = get_user_uuid() AND deleted_at IS NULL RETURNING *", now, csi.ID)
@@ -103,6 +157,10 @@ func (csi *CanvasSharedInvitation) Delete(tx *sqlx.Tx) error {
 
 func (csi CanvasSharedInvitation) Response() map[string]any {
 	csi.InviteLink = csi.buildInviteLink()
+	if csi.MaxUses != nil {
+		remaining := max(*csi.MaxUses-csi.Uses, 0)
+		csi.RemainingUses = &remaining
+	}
 	r := imissphp.ToMap(csi)
 	return r
 }
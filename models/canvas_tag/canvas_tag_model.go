@@ -0,0 +1,45 @@
+package canvas_tag_model
+
+import (
+	"fmt"
+	model "qolboard-api/models"
+	service "qolboard-api/services"
+	"qolboard-api/services/logging"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// A tag name and how many of the user's accessible canvases have it
+type TagCount struct {
+	Name     string `json:"name" db:"name"`
+	Canvases int    `json:"canvases" db:"canvases"`
+}
+
+func (tc TagCount) Response() map[string]any {
+	return service.ToMapStringAny(tc)
+}
+
+// Lists the distinct tag names on canvases the user has access to, most used first
+func GetAll(tx *sqlx.Tx, limit int, page int) ([]TagCount, error) {
+	limit = min(limit, 100)
+	offset := max(page-1, 0) * limit
+	tags := make([]TagCount, 0)
+	err := tx.Select(&tags, fmt.Sprintf(`
+SELECT min(ct.name) AS name, count(DISTINCT ct.canvas_id) AS canvases
+FROM canvas_tags ct
+JOIN canvases c ON c.id = ct.canvas_id
+WHERE ct.deleted_at IS NULL
+AND c.deleted_at IS NULL
+AND %s
+GROUP BY lower(ct.name)
+ORDER BY canvases DESC, name
+LIMIT $1
+OFFSET $2
+	`, model.SqlHasAccessToCanvas("c")), limit, offset)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvas tags", err)
+		return nil, err
+	}
+
+	return tags, nil
+}
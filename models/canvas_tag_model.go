@@ -0,0 +1,111 @@
+package model
+
+import (
+	"fmt"
+	service "qolboard-api/services"
+	"qolboard-api/services/logging"
+	relations_service "qolboard-api/services/relations"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// A free form label on a canvas, visible to everyone with access to the canvas
+type CanvasTag struct {
+	Model
+	CanvasId string  `json:"canvas_id" db:"canvas_id"`
+	UserId   string  `json:"user_id" db:"user_id"` // Who added the tag
+	Name     string  `json:"name" db:"name"`
+	Canvas   *Canvas `json:"canvas"`
+	User     *User   `json:"user"`
+}
+
+var CanvasTagRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
+
+func init() {
+	relations_service.BelongsTo(
+		"canvas",
+		CanvasTagRelations,
+		sqlSelectCanvases+" WHERE id = $1 AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE id IN (?) AND deleted_at IS NULL",
+		func(ct CanvasTag, c Canvas) CanvasTag { ct.Canvas = &c; return ct },
+		func(ct CanvasTag) any { return ct.CanvasId },
+		func(c Canvas) any { return c.ID },
+	)
+
+	relations_service.BelongsTo(
+		"user",
+		CanvasTagRelations,
+		"SELECT * FROM users WHERE id = $1",
+		"SELECT * FROM users WHERE id IN (?)",
+		func(ct CanvasTag, u User) CanvasTag { ct.User = &u; return ct },
+		func(ct CanvasTag) any { return ct.UserId },
+		func(u User) any { return u.Id },
+	)
+}
+
+func (ct CanvasTag) GetRelations() relations_service.RelationRegistry {
+	return CanvasTagRelations
+}
+
+func (ct CanvasTag) GetPrimaryKey() any {
+	return ct.ID
+}
+
+// Tags a canvas the user can edit, tagging it again with the same name (ignoring case) returns the existing tag
+func (ct *CanvasTag) Insert(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(ct, fmt.Sprintf(`
+WITH inserted AS (
+	INSERT INTO canvas_tags(created_at, updated_at, canvas_id, user_id, name)
+	SELECT $1, $1, c.id, get_user_uuid(), $2
+	FROM canvases c
+	WHERE c.id = $3
+	AND c.deleted_at IS NULL
+	AND %s
+	ON CONFLICT (canvas_id, lower(name)) WHERE deleted_at IS NULL DO NOTHING
+	RETURNING *
+)
+SELECT * FROM inserted
+UNION ALL
+SELECT ct.* FROM canvas_tags ct
+WHERE ct.canvas_id = $3
+AND lower(ct.name) = lower($2)
+AND ct.deleted_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM inserted)
+AND EXISTS (SELECT 1 FROM canvases c WHERE c.id = ct.canvas_id AND %s)
+	`, SqlCanEditCanvas("c"), SqlCanEditCanvas("c")), now, ct.Name, ct.CanvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error inserting canvas tag", err)
+		return err
+	}
+
+	return nil
+}
+
+// Removes a tag from a canvas the user can edit
+func (ct *CanvasTag) Delete(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(ct, fmt.Sprintf(`
+UPDATE canvas_tags ct
+SET deleted_at = $1, updated_at = $1
+FROM canvases c
+WHERE ct.id = $2
+AND ct.deleted_at IS NULL
+AND c.id = ct.canvas_id
+AND %s
+RETURNING ct.*
+	`, SqlCanEditCanvas("c")), now, ct.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error deleting canvas tag", err)
+		return err
+	}
+
+	return nil
+}
+
+func (ct CanvasTag) Response() map[string]any {
+	return service.ToMapStringAny(ct)
+}
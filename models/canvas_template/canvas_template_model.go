@@ -0,0 +1,49 @@
+package canvas_template_model
+
+import (
+	model "qolboard-api/models"
+	"qolboard-api/services/logging"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// A template is visible to its owner, system templates are visible to everyone
+const sqlCanUseTemplate = "(ct.user_id IS NULL OR ct.user_id = get_user_uuid())"
+
+// Lists the system templates followed by the user's own, canvas_data is left out since it can be large, fetch a single template to get it
+func GetAll(tx *sqlx.Tx, limit int, page int) ([]model.CanvasTemplate, error) {
+	limit = min(limit, 100)
+	offset := max(page-1, 0) * limit
+	templates := make([]model.CanvasTemplate, 0)
+	err := tx.Select(&templates, `
+SELECT ct.id, ct.created_at, ct.updated_at, ct.deleted_at, ct.user_id, ct.name, ct.description, NULL AS canvas_data
+FROM canvas_templates ct
+WHERE ct.deleted_at IS NULL
+AND `+sqlCanUseTemplate+`
+ORDER BY ct.user_id IS NOT NULL, ct.name
+LIMIT $1
+OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvas templates", err)
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func Get(tx *sqlx.Tx, templateId string) (*model.CanvasTemplate, error) {
+	template := &model.CanvasTemplate{}
+	err := tx.Get(template, `
+SELECT ct.*
+FROM canvas_templates ct
+WHERE ct.id = $1
+AND ct.deleted_at IS NULL
+AND `+sqlCanUseTemplate, templateId)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvas template", err)
+		return nil, err
+	}
+
+	return template, nil
+}
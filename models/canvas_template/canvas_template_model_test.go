@@ -0,0 +1,61 @@
+package canvas_template_model
+
+import (
+	"encoding/json"
+	"os"
+	canvas_service "qolboard-api/services/canvas"
+	"regexp"
+	"testing"
+)
+
+// The migration seeding the system templates, their canvas data is written out by hand
+const systemTemplatesMigration = "../../migrations/20261018090500_create_canvas_templates_table.sql"
+
+func TestSystemTemplatesAreValidCanvasData(t *testing.T) {
+	sql, err := os.ReadFile(systemTemplatesMigration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seeds := regexp.MustCompile(`'(\{"name"[^']*\})'`).FindAllSubmatch(sql, -1)
+	if len(seeds) == 0 {
+		t.Fatal("expected the migration to seed system templates")
+	}
+	for _, seed := range seeds {
+		var canvasData canvas_service.CanvasData
+		err := json.Unmarshal(seed[1], &canvasData)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pm := canvasData.PiecesManager
+		if pm == nil || len(pm.Layers) == 0 {
+			t.Fatalf("%s: expected a pieces manager with layers", canvasData.Name)
+		}
+		ids := make(map[string]bool)
+		for i, piece := range pm.Pieces {
+			if piece.ID == "" || ids[piece.ID] {
+				t.Errorf("%s: expected piece %d to have an ID of its own, got %q", canvasData.Name, i, piece.ID)
+			}
+			ids[piece.ID] = true
+			if pm.Layer(piece.LayerID) == nil {
+				t.Errorf("%s: expected piece %q to be on one of the layers, got %q", canvasData.Name, piece.ID, piece.LayerID)
+			}
+			if err := piece.Validate(); err != nil {
+				t.Errorf("%s: expected piece %q to be valid, got %v", canvasData.Name, piece.ID, err)
+			}
+		}
+
+		// Seeded as the server would save it
+		normalized, err := canvasData.DeepCopy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		normalized.Normalize()
+		before, _ := json.Marshal(canvasData)
+		after, _ := json.Marshal(normalized)
+		if string(before) != string(after) {
+			t.Errorf("%s: expected the seeded canvas data to already be normalized", canvasData.Name)
+		}
+	}
+}
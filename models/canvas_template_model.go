@@ -0,0 +1,94 @@
+package model
+
+import (
+	"encoding/json"
+	service "qolboard-api/services"
+	canvas_service "qolboard-api/services/canvas"
+	"qolboard-api/services/logging"
+	relations_service "qolboard-api/services/relations"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CanvasTemplate struct {
+	Model
+	UserId      *string                   `json:"user_id" db:"user_id"` // NULL for built in system templates
+	Name        string                    `json:"name" db:"name"`
+	Description string                    `json:"description" db:"description"`
+	CanvasData  canvas_service.CanvasData `json:"canvas_data" db:"canvas_data"`
+	User        *User                     `json:"user"`
+}
+
+var CanvasTemplateRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
+
+func init() {
+	relations_service.BelongsTo(
+		"user",
+		CanvasTemplateRelations,
+		"SELECT * FROM users WHERE id = $1",
+		"SELECT * FROM users WHERE id IN (?)",
+		func(ct CanvasTemplate, u User) CanvasTemplate { ct.User = &u; return ct },
+		func(ct CanvasTemplate) any {
+			if ct.UserId == nil {
+				return nil
+			}
+			return *ct.UserId
+		},
+		func(u User) any { return u.Id },
+	)
+}
+
+func (ct CanvasTemplate) GetRelations() relations_service.RelationRegistry {
+	return CanvasTemplateRelations
+}
+
+func (ct CanvasTemplate) GetPrimaryKey() any {
+	return ct.ID
+}
+
+// Inserts the template owned by the authenticated user
+func (ct *CanvasTemplate) Insert(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	ct.CanvasData.BackfillLayers()
+	err := ct.CanvasData.BackfillPieceIDs()
+	if err != nil {
+		return err
+	}
+
+	canvasDataBytes, err := json.Marshal(ct.CanvasData)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Get(ct, `
+INSERT INTO canvas_templates(created_at, updated_at, user_id, name, description, canvas_data)
+VALUES($1, $1, get_user_uuid(), $2, $3, $4) RETURNING *
+	`, now, ct.Name, ct.Description, string(canvasDataBytes))
+	if err != nil {
+		logging.LogError("[model]", "Error inserting canvas template", err)
+		return err
+	}
+
+	return nil
+}
+
+// Only the user's own templates can be deleted, system templates are left alone
+func (ct *CanvasTemplate) Delete(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(ct, "UPDATE canvas_templates SET deleted_at = $1 WHERE id = $2 AND user_id = get_user_uuid() AND deleted_at IS NULL RETURNING *", now, ct.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error deleting canvas template", err)
+		return err
+	}
+
+	return nil
+}
+
+func (ct CanvasTemplate) Response() map[string]any {
+	r := service.ToMapStringAny(ct)
+	r["system"] = ct.UserId == nil
+	return r
+}
@@ -0,0 +1,35 @@
+package canvas_thumbnail_model
+
+import (
+	"qolboard-api/config"
+	model "qolboard-api/models"
+	canvas_service "qolboard-api/services/canvas"
+	"qolboard-api/services/logging"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Renders a fresh thumbnail of the canvas and stores it, replacing any previous one.
+// Callers must already have checked the canvas can be edited, e.g. by having saved it.
+func Refresh(tx *sqlx.Tx, canvas *model.Canvas) error {
+	image, err := canvas_service.RenderThumbnail(canvas.CanvasData, config.CanvasThumbnailSize())
+	if err != nil {
+		logging.LogError("[model]", "Error rendering canvas thumbnail", err)
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+INSERT INTO canvas_thumbnails(created_at, updated_at, canvas_id, image)
+VALUES($1, $1, $2, $3)
+ON CONFLICT (canvas_id) DO UPDATE
+SET image = EXCLUDED.image, updated_at = EXCLUDED.updated_at
+	`, now, canvas.ID, image)
+	if err != nil {
+		logging.LogError("[model]", "Error saving canvas thumbnail", err)
+		return err
+	}
+
+	return nil
+}
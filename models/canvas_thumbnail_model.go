@@ -0,0 +1,30 @@
+package model
+
+import (
+	"encoding/base64"
+	service "qolboard-api/services"
+	relations_service "qolboard-api/services/relations"
+)
+
+// A small PNG preview of a canvas, regenerated whenever the canvas is saved
+type CanvasThumbnail struct {
+	Model
+	CanvasId string `json:"canvas_id" db:"canvas_id"`
+	Image    []byte `json:"-" db:"image"`
+}
+
+var CanvasThumbnailRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
+
+func (ct CanvasThumbnail) GetRelations() relations_service.RelationRegistry {
+	return CanvasThumbnailRelations
+}
+
+func (ct CanvasThumbnail) GetPrimaryKey() any {
+	return ct.ID
+}
+
+func (ct CanvasThumbnail) Response() map[string]any {
+	r := service.ToMapStringAny(ct)
+	r["data_url"] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(ct.Image)
+	return r
+}
@@ -0,0 +1,115 @@
+package canvas_version_model
+
+import (
+	"fmt"
+	"qolboard-api/config"
+	model "qolboard-api/models"
+	"qolboard-api/services/logging"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Lists versions for a canvas, newest first. canvas_data is left out since it can be large, fetch a single version to get it.
+func GetAllForCanvas(tx *sqlx.Tx, canvasId string, limit int, page int) ([]model.CanvasVersion, error) {
+	limit = min(limit, 100)
+	offset := max(page-1, 0) * limit
+	versions := make([]model.CanvasVersion, 0)
+	err := tx.Select(&versions, fmt.Sprintf(`
+SELECT cv.id, cv.created_at, cv.updated_at, cv.deleted_at, cv.canvas_id, cv.user_id, cv.source, NULL AS canvas_data
+FROM canvas_versions cv
+JOIN canvases c ON c.id = cv.canvas_id
+WHERE cv.canvas_id = $1
+AND cv.deleted_at IS NULL
+AND c.deleted_at IS NULL
+AND %s
+ORDER BY cv.created_at DESC
+LIMIT $2
+OFFSET $3
+	`, model.SqlHasAccessToCanvas("c")), canvasId, limit, offset)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvas versions", err)
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func Get(tx *sqlx.Tx, canvasId string, versionId string) (*model.CanvasVersion, error) {
+	version := &model.CanvasVersion{}
+	err := tx.Get(version, fmt.Sprintf(`
+SELECT cv.*
+FROM canvas_versions cv
+JOIN canvases c ON c.id = cv.canvas_id
+WHERE cv.id = $1
+AND cv.canvas_id = $2
+AND cv.deleted_at IS NULL
+AND c.deleted_at IS NULL
+AND %s
+	`, model.SqlHasAccessToCanvas("c")), versionId, canvasId)
+	if err != nil {
+		logging.LogError("[model]", "Error getting canvas version", err)
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// Snapshots the canvas' currently stored canvas_data as a new version, then prunes versions past the retention count.
+// No snapshot is written if the data is unchanged since the latest version, or if the latest version is younger than minInterval.
+func Snapshot(tx *sqlx.Tx, canvasId string, userId *string, source string, minInterval time.Duration) error {
+	now := time.Now()
+
+	_, err := tx.Exec(`
+INSERT INTO canvas_versions(created_at, updated_at, canvas_id, user_id, source, canvas_data)
+SELECT $1, $1, c.id, $2, $3, c.canvas_data
+FROM canvases c
+WHERE c.id = $4
+AND c.deleted_at IS NULL
+AND NOT EXISTS (
+	SELECT latest.id
+	FROM (
+		SELECT cv.id, cv.created_at, cv.canvas_data
+		FROM canvas_versions cv
+		WHERE cv.canvas_id = c.id
+		AND cv.deleted_at IS NULL
+		ORDER BY cv.created_at DESC
+		LIMIT 1
+	) latest
+	WHERE latest.canvas_data = c.canvas_data
+	OR latest.created_at > $5
+)
+	`, now, userId, source, canvasId, now.Add(-minInterval))
+	if err != nil {
+		logging.LogError("[model]", "Error snapshotting canvas version", err)
+		return err
+	}
+
+	return Prune(tx, canvasId, config.CanvasVersionsRetainCount())
+}
+
+// Soft deletes all but the latest keep versions of a canvas
+func Prune(tx *sqlx.Tx, canvasId string, keep int) error {
+	now := time.Now()
+
+	_, err := tx.Exec(`
+UPDATE canvas_versions
+SET deleted_at = $1, updated_at = $1
+WHERE canvas_id = $2
+AND deleted_at IS NULL
+AND id NOT IN (
+	SELECT cv.id
+	FROM canvas_versions cv
+	WHERE cv.canvas_id = $2
+	AND cv.deleted_at IS NULL
+	ORDER BY cv.created_at DESC
+	LIMIT $3
+)
+	`, now, canvasId, keep)
+	if err != nil {
+		logging.LogError("[model]", "Error pruning canvas versions", err)
+		return err
+	}
+
+	return nil
+}
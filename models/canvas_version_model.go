@@ -0,0 +1,65 @@
+package model
+
+import (
+	service "qolboard-api/services"
+	canvas_service "qolboard-api/services/canvas"
+	relations_service "qolboard-api/services/relations"
+)
+
+const (
+	CanvasVersionSourceRest      = "rest"
+	CanvasVersionSourceWebsocket = "websocket"
+	CanvasVersionSourceRestore   = "restore"
+)
+
+type CanvasVersion struct {
+	Model
+	CanvasId   string                     `json:"canvas_id" db:"canvas_id"`
+	UserId     *string                    `json:"user_id" db:"user_id"` // NULL when snapshotted by a websocket room
+	Source     string                     `json:"source" db:"source"`
+	CanvasData *canvas_service.CanvasData `json:"canvas_data,omitempty" db:"canvas_data"`
+	Canvas     *Canvas                    `json:"canvas"`
+	User       *User                      `json:"user"`
+}
+
+var CanvasVersionRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
+
+func init() {
+	relations_service.BelongsTo(
+		"canvas",
+		CanvasVersionRelations,
+		sqlSelectCanvases+" WHERE id = $1 AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE id IN (?) AND deleted_at IS NULL",
+		func(cv CanvasVersion, c Canvas) CanvasVersion { cv.Canvas = &c; return cv },
+		func(cv CanvasVersion) any { return cv.CanvasId },
+		func(c Canvas) any { return c.ID },
+	)
+
+	relations_service.BelongsTo(
+		"user",
+		CanvasVersionRelations,
+		"SELECT * FROM users WHERE id = $1",
+		"SELECT * FROM users WHERE id IN (?)",
+		func(cv CanvasVersion, u User) CanvasVersion { cv.User = &u; return cv },
+		func(cv CanvasVersion) any {
+			if cv.UserId == nil {
+				return nil
+			}
+			return *cv.UserId
+		},
+		func(u User) any { return u.Id },
+	)
+}
+
+func (cv CanvasVersion) GetRelations() relations_service.RelationRegistry {
+	return CanvasVersionRelations
+}
+
+func (cv CanvasVersion) GetPrimaryKey() any {
+	return cv.ID
+}
+
+func (cv CanvasVersion) Response() map[string]any {
+	r := service.ToMapStringAny(cv)
+	return r
+}
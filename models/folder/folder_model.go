@@ -0,0 +1,47 @@
+package folder_model
+
+import (
+	model "qolboard-api/models"
+	"qolboard-api/services/logging"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Lists all of the user's folders, flat, the tree can be built from each folder's parent_id
+func GetAll(tx *sqlx.Tx, limit int, page int) ([]model.Folder, error) {
+	limit = min(limit, 100)
+	offset := max(page-1, 0) * limit
+	folders := make([]model.Folder, 0)
+	err := tx.Select(&folders, `
+SELECT *
+FROM folders f
+WHERE f.user_id = get_user_uuid()
+AND f.deleted_at IS NULL
+ORDER BY f.name, f.id
+LIMIT $1
+OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		logging.LogError("[model]", "Error getting folders", err)
+		return nil, err
+	}
+
+	return folders, nil
+}
+
+func Get(tx *sqlx.Tx, folderId string) (*model.Folder, error) {
+	folder := &model.Folder{}
+	err := tx.Get(folder, `
+SELECT *
+FROM folders f
+WHERE f.id = $1
+AND f.user_id = get_user_uuid()
+AND f.deleted_at IS NULL
+	`, folderId)
+	if err != nil {
+		logging.LogError("[model]", "Error getting folder", err)
+		return nil, err
+	}
+
+	return folder, nil
+}
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	service "qolboard-api/services"
+	"qolboard-api/services/logging"
+	relations_service "qolboard-api/services/relations"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Folder struct {
+	Model
+	UserId   string   `json:"user_id" db:"user_id"`
+	ParentId *string  `json:"parent_id" db:"parent_id"` // NULL for top level folders
+	Name     string   `json:"name" db:"name"`
+	Parent   *Folder  `json:"parent"`
+	Folders  []Folder `json:"folders"`
+	Canvases []Canvas `json:"canvases"`
+	User     *User    `json:"user"`
+}
+
+var (
+	ErrFolderNotFound = errors.New("folder not found")
+	ErrFolderCycle    = errors.New("folder cannot be moved into itself")
+)
+
+var FolderRelations relations_service.RelationRegistry = relations_service.NewRelationRegistry()
+
+func init() {
+	relations_service.BelongsTo(
+		"user",
+		FolderRelations,
+		"SELECT * FROM users WHERE id = $1",
+		"SELECT * FROM users WHERE id IN (?)",
+		func(f Folder, u User) Folder { f.User = &u; return f },
+		func(f Folder) any { return f.UserId },
+		func(u User) any { return u.Id },
+	)
+
+	relations_service.BelongsTo(
+		"parent",
+		FolderRelations,
+		"SELECT * FROM folders WHERE id = $1 AND deleted_at IS NULL",
+		"SELECT * FROM folders WHERE id IN (?) AND deleted_at IS NULL",
+		func(f Folder, p Folder) Folder { f.Parent = &p; return f },
+		func(f Folder) any {
+			if f.ParentId == nil {
+				return nil
+			}
+			return *f.ParentId
+		},
+		func(p Folder) any { return p.ID },
+	)
+
+	relations_service.HasMany(
+		"folders",
+		FolderRelations,
+		"SELECT * FROM folders WHERE parent_id = $1 AND deleted_at IS NULL ORDER BY name",
+		"SELECT * FROM folders WHERE parent_id IN (?) AND deleted_at IS NULL ORDER BY name",
+		func(f Folder, children []Folder) Folder { f.Folders = children; return f },
+		func(f Folder) any { return f.ID },
+		func(child Folder) any {
+			if child.ParentId == nil {
+				return nil
+			}
+			return *child.ParentId
+		},
+	)
+
+	relations_service.HasMany(
+		"canvases",
+		FolderRelations,
+		sqlSelectCanvases+" WHERE folder_id = $1 AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE folder_id IN (?) AND deleted_at IS NULL",
+		func(f Folder, c []Canvas) Folder { f.Canvases = c; return f },
+		func(f Folder) any { return f.ID },
+		func(c Canvas) any {
+			if c.FolderId == nil {
+				return nil
+			}
+			return *c.FolderId
+		},
+	)
+}
+
+func (f Folder) GetRelations() relations_service.RelationRegistry {
+	return FolderRelations
+}
+
+func (f Folder) GetPrimaryKey() any {
+	return f.ID
+}
+
+func (f *Folder) Insert(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := checkFolderOwned(tx, f.ParentId)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Get(f, `
+INSERT INTO folders(created_at, updated_at, user_id, parent_id, name)
+VALUES($1, $1, get_user_uuid(), $2, $3) RETURNING *
+	`, now, f.ParentId, f.Name)
+	if err != nil {
+		logging.LogError("[model]", "Error inserting folder", err)
+		return err
+	}
+
+	return nil
+}
+
+// Renames and moves one of the user's folders, it may not be moved into itself or one of its subfolders
+func (f *Folder) Update(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := checkFolderOwned(tx, f.ParentId)
+	if err != nil {
+		return err
+	}
+
+	if f.ParentId != nil {
+		var cycle bool
+		err = tx.Get(&cycle, `
+WITH RECURSIVE ancestors AS (
+	SELECT id, parent_id FROM folders WHERE id = $1
+	UNION
+	SELECT f.id, f.parent_id FROM folders f JOIN ancestors a ON f.id = a.parent_id
+)
+SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+		`, *f.ParentId, f.ID)
+		if err != nil {
+			logging.LogError("[model]", "Error checking folder ancestors", err)
+			return err
+		}
+		if cycle {
+			return ErrFolderCycle
+		}
+	}
+
+	err = tx.Get(f, `
+UPDATE folders
+SET name = $1, parent_id = $2, updated_at = $3
+WHERE id = $4
+AND user_id = get_user_uuid()
+AND deleted_at IS NULL
+RETURNING *
+	`, f.Name, f.ParentId, now, f.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error updating folder", err)
+		return err
+	}
+
+	return nil
+}
+
+// Deletes one of the user's folders, its subfolders and canvases move up into its parent
+func (f *Folder) Delete(tx *sqlx.Tx) error {
+	now := time.Now()
+
+	err := tx.Get(f, "UPDATE folders SET deleted_at = $1 WHERE id = $2 AND user_id = get_user_uuid() AND deleted_at IS NULL RETURNING *", now, f.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error deleting folder", err)
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE folders SET parent_id = $1, updated_at = $2 WHERE parent_id = $3 AND deleted_at IS NULL", f.ParentId, now, f.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error moving subfolders of deleted folder", err)
+		return err
+	}
+
+	// Canvases in the trash move too, so restoring one doesn't put it back into a deleted folder
+	_, err = tx.Exec("UPDATE canvases SET folder_id = $1 WHERE folder_id = $2", f.ParentId, f.ID)
+	if err != nil {
+		logging.LogError("[model]", "Error moving canvases of deleted folder", err)
+		return err
+	}
+
+	return nil
+}
+
+func (f Folder) Response() map[string]any {
+	return service.ToMapStringAny(f)
+}
+
+// Returns ErrFolderNotFound unless the folder is one of the user's, a nil folder is the top level and always allowed
+func checkFolderOwned(tx *sqlx.Tx, folderId *string) error {
+	if folderId == nil {
+		return nil
+	}
+
+	var id string
+	err := tx.Get(&id, "SELECT id FROM folders WHERE id = $1 AND user_id = get_user_uuid() AND deleted_at IS NULL", *folderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrFolderNotFound
+	}
+	if err != nil {
+		logging.LogError("[model]", "Error checking folder", err)
+	}
+	return err
+}
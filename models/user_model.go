@@ -24,6 +24,7 @@ type User struct {
 	UserRefreshTokens        []UserRefreshToken   `json:"user_refresh_tokens"`
 	Canvases                 []Canvas             `json:"canvases"`
 	CanvasSharedAccesses     []CanvasSharedAccess `json:"canvas_shared_accesses"`
+	Favorites                []CanvasFavorite     `json:"favorites"`
 }
 
 var UserRelations = relations_service.NewRelationRegistry()
@@ -57,13 +58,24 @@ func init() {
 	relations_service.HasMany(
 		"canvases",
 		UserRelations,
-		"SELECT * FROM canvases WHERE user_id = $1 AND deleted_at IS NULL",
-		"SELECT * FROM canvases WHERE user_id IN (?) AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE user_id = $1 AND deleted_at IS NULL",
+		sqlSelectCanvases+" WHERE user_id IN (?) AND deleted_at IS NULL",
 		func(u User, c []Canvas) User { u.Canvases = c; return u },
 		func(u User) any { return u.Id },
 		func(c Canvas) any { return c.UserId },
 	)
 
+	// Only the authenticated user's own favorites, favorites are private
+	relations_service.HasMany(
+		"favorites",
+		UserRelations,
+		"SELECT * FROM canvas_favorites WHERE user_id = $1 AND user_id = get_user_uuid() AND deleted_at IS NULL ORDER BY created_at DESC",
+		"SELECT * FROM canvas_favorites WHERE user_id IN (?) AND user_id = get_user_uuid() AND deleted_at IS NULL ORDER BY created_at DESC",
+		func(u User, cf []CanvasFavorite) User { u.Favorites = cf; return u },
+		func(u User) any { return u.Id },
+		func(cf CanvasFavorite) any { return cf.UserId },
+	)
+
 	relations_service.HasMany(
 		"canvas_shared_accesses",
 		UserRelations,
@@ -0,0 +1,117 @@
+package canvas_service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Canvases saved before layers existed have all their pieces moved into this layer
+const DefaultLayerID = "default"
+
+var (
+	ErrLayerNotFound = errors.New("layer not found")
+	ErrLayerLocked   = errors.New("layer is locked")
+)
+
+type Layer struct {
+	ID     string `json:"id"` // Server assigned, stable across collaborators
+	Name   string `json:"name"`
+	Hidden bool   `json:"hidden"`
+	Locked bool   `json:"locked"` // Pieces on locked layers can not be added, changed or removed
+}
+
+func NewLayerID() (string, error) {
+	return NewPieceID()
+}
+
+// Makes sure the canvas has at least one layer and every piece is on one of its layers, pieces without a known layer go on the bottom layer
+func (canvasData *CanvasData) BackfillLayers() {
+	pm := canvasData.PiecesManager
+	if pm == nil {
+		return
+	}
+
+	pm.Layers = slices.DeleteFunc(pm.Layers, func(l *Layer) bool { return l == nil })
+	if len(pm.Layers) == 0 {
+		pm.Layers = []*Layer{{ID: DefaultLayerID, Name: "Layer 1"}}
+	}
+
+	for _, piece := range pm.Pieces {
+		if piece != nil && pm.Layer(piece.LayerID) == nil {
+			piece.LayerID = pm.Layers[0].ID
+		}
+	}
+}
+
+// Returns the layer with the given ID, or nil if it does not exist
+func (pm *PiecesManager) Layer(id string) *Layer {
+	for _, layer := range pm.Layers {
+		if layer != nil && layer.ID == id {
+			return layer
+		}
+	}
+	return nil
+}
+
+// Returns an error unless pieces can be put on, changed on or removed from the layer
+func (pm *PiecesManager) CheckLayerEditable(id string) error {
+	layer := pm.Layer(id)
+	if layer == nil {
+		return fmt.Errorf("%w: %q", ErrLayerNotFound, id)
+	}
+	if layer.Locked {
+		return fmt.Errorf("%w: %q", ErrLayerLocked, layer.Name)
+	}
+	return nil
+}
+
+// Puts the layers in the given order, ids must list every layer exactly once
+func (pm *PiecesManager) ReorderLayers(ids []string) error {
+	if len(ids) != len(pm.Layers) {
+		return fmt.Errorf("expected %d layer ids, got %d", len(pm.Layers), len(ids))
+	}
+
+	layers := make([]*Layer, 0, len(ids))
+	for _, id := range ids {
+		layer := pm.Layer(id)
+		if layer == nil {
+			return fmt.Errorf("%w: %q", ErrLayerNotFound, id)
+		}
+		if slices.Contains(layers, layer) {
+			return fmt.Errorf("layer %q listed more than once", id)
+		}
+		layers = append(layers, layer)
+	}
+
+	pm.Layers = layers
+	return nil
+}
+
+// The pieces to draw, bottom layer first, leaving out pieces on hidden layers.
+// Pieces keep their order within a layer, and pieces on unknown layers are drawn with the bottom layer
+func (pm *PiecesManager) VisiblePieces() []*PieceData {
+	rank := make(map[string]int, len(pm.Layers))
+	for i, layer := range pm.Layers {
+		if layer != nil {
+			rank[layer.ID] = i
+		}
+	}
+
+	visible := make([]*PieceData, 0, len(pm.Pieces))
+	for _, piece := range pm.Pieces {
+		if piece == nil {
+			continue
+		}
+		if layer := pm.Layer(piece.LayerID); layer != nil && layer.Hidden {
+			continue
+		}
+		visible = append(visible, piece)
+	}
+
+	slices.SortStableFunc(visible, func(a, b *PieceData) int {
+		return rank[a.LayerID] - rank[b.LayerID]
+	})
+
+	return visible
+}
@@ -0,0 +1,43 @@
+package canvas_service
+
+import (
+	"testing"
+)
+
+func TestVisiblePieces(t *testing.T) {
+	canvasData := CanvasData{
+		PiecesManager: &PiecesManager{
+			Pieces: []*PieceData{
+				{ID: "a", LayerID: "top"},
+				{ID: "b"},
+				{ID: "c", LayerID: "hidden"},
+				{ID: "d", LayerID: "bottom"},
+			},
+			Layers: []*Layer{
+				{ID: "bottom"},
+				{ID: "hidden", Hidden: true},
+				{ID: "top"},
+			},
+		},
+	}
+	canvasData.BackfillLayers()
+
+	if canvasData.PiecesManager.Pieces[1].LayerID != "bottom" {
+		t.Fatalf("expected the piece without a layer to be moved to the bottom layer, got %q", canvasData.PiecesManager.Pieces[1].LayerID)
+	}
+
+	visible := canvasData.PiecesManager.VisiblePieces()
+	expected := []string{"b", "d", "a"}
+	if len(visible) != len(expected) {
+		t.Fatalf("expected %d visible pieces, got %d", len(expected), len(visible))
+	}
+	for i, id := range expected {
+		if visible[i].ID != id {
+			t.Errorf("expected piece %d to be %q, got %q", i, id, visible[i].ID)
+		}
+	}
+
+	if err := canvasData.PiecesManager.ReorderLayers([]string{"top", "top", "bottom"}); err == nil {
+		t.Errorf("expected reordering with a repeated layer to fail")
+	}
+}
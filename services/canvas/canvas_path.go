@@ -0,0 +1,329 @@
+package canvas_service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Parses SVG path data into polylines, one per subpath, with curves and arcs flattened into straight segments
+func ParsePath(d string) ([][]Point, error) {
+	p := &pathParser{d: d}
+	return p.parse()
+}
+
+type pathParser struct {
+	d   string
+	pos int
+
+	subpaths [][]Point
+	current  []Point
+	cur      Point
+	start    Point
+	ctrl     Point // Reflected by S and T, only meaningful when the previous command was a curve of the same kind
+	lastCmd  byte
+}
+
+func (p *pathParser) parse() ([][]Point, error) {
+	var cmd byte
+	for {
+		p.skipSeparators()
+		if p.pos >= len(p.d) {
+			break
+		}
+
+		c := p.d[p.pos]
+		if isCommand(c) {
+			cmd = c
+			p.pos++
+		} else if cmd == 0 {
+			return nil, fmt.Errorf("path data must start with a command, found %q", c)
+		}
+
+		// Pairs following a moveto are implicit linetos
+		err := p.command(cmd)
+		if err != nil {
+			return nil, err
+		}
+		switch cmd {
+		case 'M':
+			cmd = 'L'
+		case 'm':
+			cmd = 'l'
+		case 'Z', 'z':
+			cmd = 0
+		}
+	}
+	p.endSubpath()
+
+	return p.subpaths, nil
+}
+
+func (p *pathParser) command(cmd byte) error {
+	relative := cmd >= 'a'
+	origin := Point{}
+	if relative {
+		origin = p.cur
+	}
+
+	var nums []float64
+	var err error
+	switch cmd {
+	case 'Z', 'z':
+		if len(p.current) > 0 {
+			p.lineTo(p.start)
+		}
+		p.cur = p.start
+		p.endSubpath()
+		p.lastCmd = cmd
+		return nil
+	case 'H', 'h', 'V', 'v':
+		nums, err = p.numbers(1)
+	case 'M', 'm', 'L', 'l', 'T', 't':
+		nums, err = p.numbers(2)
+	case 'S', 's', 'Q', 'q':
+		nums, err = p.numbers(4)
+	case 'C', 'c':
+		nums, err = p.numbers(6)
+	case 'A', 'a':
+		nums, err = p.arcNumbers()
+	default:
+		return fmt.Errorf("unsupported path command %q", cmd)
+	}
+	if err != nil {
+		return err
+	}
+
+	pt := func(i int) Point {
+		return Point{X: origin.X + nums[i], Y: origin.Y + nums[i+1]}
+	}
+
+	switch cmd {
+	case 'M', 'm':
+		p.endSubpath()
+		p.cur = pt(0)
+		p.start = p.cur
+		p.current = []Point{p.cur}
+	case 'L', 'l':
+		p.lineTo(pt(0))
+	case 'H', 'h':
+		p.lineTo(Point{X: origin.X + nums[0], Y: p.cur.Y})
+	case 'V', 'v':
+		p.lineTo(Point{X: p.cur.X, Y: origin.Y + nums[0]})
+	case 'C', 'c':
+		p.cubicTo(pt(0), pt(2), pt(4))
+	case 'S', 's':
+		p.cubicTo(p.reflect('C', 'c', 'S', 's'), pt(0), pt(2))
+	case 'Q', 'q':
+		p.quadTo(pt(0), pt(2))
+	case 'T', 't':
+		p.quadTo(p.reflect('Q', 'q', 'T', 't'), pt(0))
+	case 'A', 'a':
+		p.arcTo(nums[0], nums[1], nums[2], nums[3] != 0, nums[4] != 0, Point{X: origin.X + nums[5], Y: origin.Y + nums[6]})
+	}
+	p.lastCmd = cmd
+
+	return nil
+}
+
+// The control point implied by S or T, the previous control point mirrored around the current point
+func (p *pathParser) reflect(kinds ...byte) Point {
+	for _, k := range kinds {
+		if p.lastCmd == k {
+			return Point{X: 2*p.cur.X - p.ctrl.X, Y: 2*p.cur.Y - p.ctrl.Y}
+		}
+	}
+	return p.cur
+}
+
+func (p *pathParser) lineTo(to Point) {
+	if len(p.current) == 0 {
+		p.current = []Point{p.cur}
+	}
+	p.current = append(p.current, to)
+	p.cur = to
+}
+
+func (p *pathParser) cubicTo(c1 Point, c2 Point, to Point) {
+	from := p.cur
+	n := flattenSteps(from, c1, c2, to)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		mt := 1 - t
+		p.lineTo(Point{
+			X: mt*mt*mt*from.X + 3*mt*mt*t*c1.X + 3*mt*t*t*c2.X + t*t*t*to.X,
+			Y: mt*mt*mt*from.Y + 3*mt*mt*t*c1.Y + 3*mt*t*t*c2.Y + t*t*t*to.Y,
+		})
+	}
+	p.ctrl = c2
+}
+
+func (p *pathParser) quadTo(c Point, to Point) {
+	from := p.cur
+	n := flattenSteps(from, c, to)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		mt := 1 - t
+		p.lineTo(Point{
+			X: mt*mt*from.X + 2*mt*t*c.X + t*t*to.X,
+			Y: mt*mt*from.Y + 2*mt*t*c.Y + t*t*to.Y,
+		})
+	}
+	p.ctrl = c
+}
+
+// Converts an endpoint parameterised elliptical arc to its center parameterisation and flattens it, see the SVG spec's implementation notes
+func (p *pathParser) arcTo(rx float64, ry float64, rotation float64, largeArc bool, sweep bool, to Point) {
+	from := p.cur
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || from == to {
+		p.lineTo(to)
+		return
+	}
+
+	phi := rotation * math.Pi / 180
+	cosPhi, sinPhi := math.Cos(phi), math.Sin(phi)
+	dx, dy := (from.X-to.X)/2, (from.Y-to.Y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+
+	// Scale up radii which are too small to reach the end point
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (from.X+to.X)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (from.Y+to.Y)/2
+
+	theta1 := math.Atan2((y1-cy1)/ry, (x1-cx1)/rx)
+	delta := math.Atan2((-y1-cy1)/ry, (-x1-cx1)/rx) - theta1
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+
+	n := max(4, min(64, int(math.Ceil(math.Abs(delta)*math.Max(rx, ry)/2))))
+	for i := 1; i <= n; i++ {
+		theta := theta1 + delta*float64(i)/float64(n)
+		x, y := rx*math.Cos(theta), ry*math.Sin(theta)
+		p.lineTo(Point{X: cosPhi*x - sinPhi*y + cx, Y: sinPhi*x + cosPhi*y + cy})
+	}
+	p.cur = to
+}
+
+// Number of straight segments to flatten a curve into, based on the length of its control polygon
+func flattenSteps(points ...Point) int {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+	}
+	return max(2, min(64, int(math.Ceil(length/2))))
+}
+
+func (p *pathParser) endSubpath() {
+	if len(p.current) > 0 {
+		p.subpaths = append(p.subpaths, p.current)
+	}
+	p.current = nil
+}
+
+func (p *pathParser) numbers(n int) ([]float64, error) {
+	nums := make([]float64, 0, n)
+	for len(nums) < n {
+		p.skipSeparators()
+		num, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, num)
+	}
+	return nums, nil
+}
+
+// Arc arguments, the two flags are single characters which may be written without separators, e.g. "a1 1 0 011 1"
+func (p *pathParser) arcNumbers() ([]float64, error) {
+	nums, err := p.numbers(3)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < 2; i++ {
+		p.skipSeparators()
+		if p.pos >= len(p.d) || (p.d[p.pos] != '0' && p.d[p.pos] != '1') {
+			return nil, fmt.Errorf("expected an arc flag at offset %d of path data", p.pos)
+		}
+		nums = append(nums, float64(p.d[p.pos]-'0'))
+		p.pos++
+	}
+	end, err := p.numbers(2)
+	if err != nil {
+		return nil, err
+	}
+	return append(nums, end...), nil
+}
+
+func (p *pathParser) number() (float64, error) {
+	start := p.pos
+	if p.pos < len(p.d) && (p.d[p.pos] == '+' || p.d[p.pos] == '-') {
+		p.pos++
+	}
+	seenDot, seenDigit := false, false
+	for p.pos < len(p.d) {
+		c := p.d[p.pos]
+		if c >= '0' && c <= '9' {
+			seenDigit = true
+		} else if c == '.' && !seenDot {
+			seenDot = true
+		} else {
+			break
+		}
+		p.pos++
+	}
+	if seenDigit && p.pos < len(p.d) && (p.d[p.pos] == 'e' || p.d[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.d) && (p.d[p.pos] == '+' || p.d[p.pos] == '-') {
+			p.pos++
+		}
+		for p.pos < len(p.d) && p.d[p.pos] >= '0' && p.d[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	if !seenDigit {
+		return 0, fmt.Errorf("expected a number at offset %d of path data", start)
+	}
+
+	return strconv.ParseFloat(p.d[start:p.pos], 64)
+}
+
+func (p *pathParser) skipSeparators() {
+	for p.pos < len(p.d) {
+		switch p.d[p.pos] {
+		case ' ', ',', '\t', '\n', '\r', '\f':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func isCommand(c byte) bool {
+	switch c {
+	case 'M', 'm', 'L', 'l', 'H', 'h', 'V', 'v', 'C', 'c', 'S', 's', 'Q', 'q', 'T', 't', 'A', 'a', 'Z', 'z':
+		return true
+	}
+	return false
+}
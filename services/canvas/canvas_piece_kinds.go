@@ -0,0 +1,229 @@
+package canvas_service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// A piece's kind decides which of its payloads is used, pieces saved before kinds existed are freehand paths
+const (
+	PieceKindPath      = "path"
+	PieceKindText      = "text"
+	PieceKindRectangle = "rectangle"
+	PieceKindEllipse   = "ellipse"
+	PieceKindArrow     = "arrow"
+	PieceKindImage     = "image"
+)
+
+var PieceKinds = []string{
+	PieceKindPath,
+	PieceKindText,
+	PieceKindRectangle,
+	PieceKindEllipse,
+	PieceKindArrow,
+	PieceKindImage,
+}
+
+const (
+	maxTextLength   = 10000
+	minTextFontSize = 1
+	maxTextFontSize = 1000
+)
+
+// Positions are in the piece's own coordinates, its move matrix places it on the canvas like it does for paths
+type TextData struct {
+	Content  string  `json:"content"`
+	FontSize float64 `json:"fontSize"`
+	X        float64 `json:"x"` // Top left of the text
+	Y        float64 `json:"y"`
+}
+
+// The box of a rectangle, or the box an ellipse is drawn in
+type ShapeData struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type ArrowData struct {
+	X1 float64 `json:"x1"` // Tail
+	Y1 float64 `json:"y1"`
+	X2 float64 `json:"x2"` // Head
+	Y2 float64 `json:"y2"`
+}
+
+type ImageData struct {
+	AssetID string `json:"assetId"`
+	ShapeData
+}
+
+func (piece *PieceData) UnmarshalJSON(bytes []byte) error {
+	type plain PieceData // Without the method, so unmarshalling doesn't recurse
+	var p plain
+	err := json.Unmarshal(bytes, &p)
+	if err != nil {
+		return err
+	}
+	if p.Kind == "" {
+		p.Kind = PieceKindPath
+	}
+	*piece = PieceData(p)
+	return nil
+}
+
+// Checks the piece's settings and transform, and that it has the payload its kind needs and only that payload
+func (piece *PieceData) Validate() error {
+	if !slices.Contains(PieceKinds, piece.Kind) {
+		return fmt.Errorf("unknown piece kind %q, must be one of: %s", piece.Kind, strings.Join(PieceKinds, ", "))
+	}
+	err := piece.Settings.Validate()
+	if err != nil {
+		return err
+	}
+	err = piece.Move.Validate()
+	if err != nil {
+		return err
+	}
+
+	payloads := map[string]bool{
+		PieceKindPath:      piece.Path != "",
+		PieceKindText:      piece.Text != nil,
+		PieceKindRectangle: piece.Rectangle != nil,
+		PieceKindEllipse:   piece.Ellipse != nil,
+		PieceKindArrow:     piece.Arrow != nil,
+		PieceKindImage:     piece.Image != nil,
+	}
+	for kind, present := range payloads {
+		if kind == piece.Kind && !present {
+			return fmt.Errorf("%s pieces need a %s", piece.Kind, kind)
+		}
+		if kind != piece.Kind && present {
+			return fmt.Errorf("%s pieces can not have a %s", piece.Kind, kind)
+		}
+	}
+
+	switch piece.Kind {
+	case PieceKindPath:
+		return validatePath(piece.Path)
+	case PieceKindText:
+		t := piece.Text
+		if strings.TrimSpace(t.Content) == "" {
+			return errors.New("text content can not be blank")
+		}
+		if utf8.RuneCountInString(t.Content) > maxTextLength {
+			return fmt.Errorf("text content can not be longer than %d characters", maxTextLength)
+		}
+		if !(t.FontSize >= minTextFontSize && t.FontSize <= maxTextFontSize) {
+			return fmt.Errorf("text font size must be between %d and %d", minTextFontSize, maxTextFontSize)
+		}
+		return checkFinite(t.X, t.Y)
+	case PieceKindRectangle:
+		return piece.Rectangle.validate()
+	case PieceKindEllipse:
+		return piece.Ellipse.validate()
+	case PieceKindArrow:
+		a := piece.Arrow
+		err := checkFinite(a.X1, a.Y1, a.X2, a.Y2)
+		if err != nil {
+			return err
+		}
+		if a.X1 == a.X2 && a.Y1 == a.Y2 {
+			return errors.New("arrow can not start and end at the same point")
+		}
+	case PieceKindImage:
+		if piece.Image.AssetID == "" {
+			return errors.New("image needs an asset id")
+		}
+		return piece.Image.ShapeData.validate()
+	}
+
+	return nil
+}
+
+func (s *ShapeData) validate() error {
+	err := checkFinite(s.X, s.Y, s.Width, s.Height)
+	if err != nil {
+		return err
+	}
+	if s.Width <= 0 || s.Height <= 0 {
+		return errors.New("width and height must be greater than 0")
+	}
+	return nil
+}
+
+func checkFinite(values ...float64) error {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("coordinates must be finite numbers")
+		}
+	}
+	return nil
+}
+
+// The path a piece's stroke follows, in its own coordinates. Empty for kinds which aren't drawn as strokes, text and images
+func (piece *PieceData) Outline() string {
+	switch piece.Kind {
+	case PieceKindPath, "":
+		return piece.Path
+	case PieceKindRectangle:
+		if piece.Rectangle != nil {
+			return rectanglePath(*piece.Rectangle)
+		}
+	case PieceKindEllipse:
+		if e := piece.Ellipse; e != nil {
+			rx, ry := e.Width/2, e.Height/2
+			cx, cy := e.X+rx, e.Y+ry
+			return fmt.Sprintf("M %s %s A %s %s 0 1 0 %s %s A %s %s 0 1 0 %s %s Z",
+				formatFloat(cx-rx), formatFloat(cy),
+				formatFloat(rx), formatFloat(ry), formatFloat(cx+rx), formatFloat(cy),
+				formatFloat(rx), formatFloat(ry), formatFloat(cx-rx), formatFloat(cy))
+		}
+	case PieceKindArrow:
+		if a := piece.Arrow; a != nil {
+			// Two short strokes back from the head, scaled with the stroke so the head stays visible
+			size := 10.0
+			if piece.Settings != nil {
+				size += float64(piece.Settings.Size) * 2
+			}
+			angle := math.Atan2(a.Y2-a.Y1, a.X2-a.X1)
+			left := Point{X: a.X2 - size*math.Cos(angle-math.Pi/6), Y: a.Y2 - size*math.Sin(angle-math.Pi/6)}
+			right := Point{X: a.X2 - size*math.Cos(angle+math.Pi/6), Y: a.Y2 - size*math.Sin(angle+math.Pi/6)}
+			return fmt.Sprintf("M %s %s L %s %s M %s %s L %s %s L %s %s",
+				formatFloat(a.X1), formatFloat(a.Y1), formatFloat(a.X2), formatFloat(a.Y2),
+				formatFloat(left.X), formatFloat(left.Y), formatFloat(a.X2), formatFloat(a.Y2), formatFloat(right.X), formatFloat(right.Y))
+		}
+	}
+	return ""
+}
+
+// The outline, or for text and images the box they take up, used to work out bounds
+func (piece *PieceData) extentPath() string {
+	switch piece.Kind {
+	case PieceKindText:
+		if t := piece.Text; t != nil {
+			// Without font metrics this is an estimate, average glyphs are a little over half as wide as they are high
+			lines := strings.Split(t.Content, "\n")
+			longest := 0
+			for _, line := range lines {
+				longest = max(longest, utf8.RuneCountInString(line))
+			}
+			return rectanglePath(ShapeData{X: t.X, Y: t.Y, Width: 0.6 * t.FontSize * float64(longest), Height: 1.2 * t.FontSize * float64(len(lines))})
+		}
+	case PieceKindImage:
+		if piece.Image != nil {
+			return rectanglePath(piece.Image.ShapeData)
+		}
+	}
+	return piece.Outline()
+}
+
+func rectanglePath(s ShapeData) string {
+	return fmt.Sprintf("M %s %s H %s V %s H %s Z",
+		formatFloat(s.X), formatFloat(s.Y), formatFloat(s.X+s.Width), formatFloat(s.Y+s.Height), formatFloat(s.X))
+}
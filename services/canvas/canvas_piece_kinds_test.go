@@ -0,0 +1,102 @@
+package canvas_service
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPieceKindDefaultsToPath(t *testing.T) {
+	var piece PieceData
+	err := json.Unmarshal([]byte(`{"id":"a","path":"M 0 0 L 10 10","settings":{"size":2,"color":"#000000"}}`), &piece)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if piece.Kind != PieceKindPath {
+		t.Fatalf("expected pieces without a kind to be paths, got %q", piece.Kind)
+	}
+	if err := piece.Validate(); err != nil {
+		t.Errorf("expected an old path piece to be valid, got %v", err)
+	}
+}
+
+func TestPieceValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		piece PieceData
+		valid bool
+	}{
+		{"path", PieceData{Kind: PieceKindPath, Path: "M 0 0 L 1 1"}, true},
+		{"empty path", PieceData{Kind: PieceKindPath}, false},
+		{"unknown kind", PieceData{Kind: "triangle", Path: "M 0 0"}, false},
+		{"text", PieceData{Kind: PieceKindText, Text: &TextData{Content: "hi", FontSize: 16}}, true},
+		{"blank text", PieceData{Kind: PieceKindText, Text: &TextData{Content: " \n", FontSize: 16}}, false},
+		{"long text", PieceData{Kind: PieceKindText, Text: &TextData{Content: strings.Repeat("a", maxTextLength+1), FontSize: 16}}, false},
+		{"zero font size", PieceData{Kind: PieceKindText, Text: &TextData{Content: "hi"}}, false},
+		{"text with a path", PieceData{Kind: PieceKindText, Path: "M 0 0", Text: &TextData{Content: "hi", FontSize: 16}}, false},
+		{"rectangle", PieceData{Kind: PieceKindRectangle, Rectangle: &ShapeData{Width: 10, Height: 5}}, true},
+		{"flat rectangle", PieceData{Kind: PieceKindRectangle, Rectangle: &ShapeData{Width: 10}}, false},
+		{"rectangle without its payload", PieceData{Kind: PieceKindRectangle, Ellipse: &ShapeData{Width: 10, Height: 5}}, false},
+		{"infinite ellipse", PieceData{Kind: PieceKindEllipse, Ellipse: &ShapeData{Width: math.Inf(1), Height: 5}}, false},
+		{"arrow", PieceData{Kind: PieceKindArrow, Arrow: &ArrowData{X2: 10}}, true},
+		{"zero length arrow", PieceData{Kind: PieceKindArrow, Arrow: &ArrowData{X1: 3, Y1: 3, X2: 3, Y2: 3}}, false},
+		{"image", PieceData{Kind: PieceKindImage, Image: &ImageData{AssetID: "x", ShapeData: ShapeData{Width: 1, Height: 1}}}, true},
+		{"image without an asset", PieceData{Kind: PieceKindImage, Image: &ImageData{ShapeData: ShapeData{Width: 1, Height: 1}}}, false},
+	}
+
+	for _, test := range tests {
+		// Settings are checked on their own in TestPieceSettingsValidate
+		test.piece.Settings = &PieceSettings{Size: 2, Coloer: "#000000"}
+		err := test.piece.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: expected to be valid, got %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected to be invalid", test.name)
+		}
+	}
+}
+
+func TestShapeBounds(t *testing.T) {
+	pieces := []*PieceData{
+		{Kind: PieceKindRectangle, Rectangle: &ShapeData{X: 10, Y: 20, Width: 30, Height: 40}},
+		{Kind: PieceKindEllipse, Ellipse: &ShapeData{X: 10, Y: 20, Width: 30, Height: 40}},
+		{Kind: PieceKindImage, Image: &ImageData{AssetID: "x", ShapeData: ShapeData{X: 10, Y: 20, Width: 30, Height: 40}}},
+	}
+	for _, piece := range pieces {
+		bounds, ok := piece.Bounds()
+		if !ok {
+			t.Fatalf("%s: expected bounds", piece.Kind)
+		}
+		if math.Abs(bounds.Left-10) > 0.5 || math.Abs(bounds.Top-20) > 0.5 || math.Abs(bounds.Right-40) > 0.5 || math.Abs(bounds.Bottom-60) > 0.5 {
+			t.Errorf("%s: expected bounds of the shape's box, got %+v", piece.Kind, bounds)
+		}
+	}
+
+	text := &PieceData{Kind: PieceKindText, Text: &TextData{Content: "ab\nc", FontSize: 10, X: 5, Y: 5}, Move: NewDOMMatrix(1, 0, 0, 1, 100, 0)}
+	bounds, ok := text.Bounds()
+	if !ok || bounds.Left != 105 || bounds.Top != 5 || bounds.Bottom <= bounds.Top || bounds.Right <= bounds.Left {
+		t.Errorf("expected text bounds to start at its moved position, got %+v", bounds)
+	}
+}
+
+func TestRenderSVGText(t *testing.T) {
+	canvasData := CanvasData{
+		PiecesManager: &PiecesManager{
+			Pieces: []*PieceData{
+				{ID: "a", Kind: PieceKindText, Text: &TextData{Content: "<hi>\nthere", FontSize: 12}},
+				{ID: "b", Kind: PieceKindArrow, Arrow: &ArrowData{X2: 10, Y2: 10}},
+			},
+		},
+	}
+	canvasData.BackfillLayers()
+
+	svg := string(RenderSVG(canvasData, SVGOptions{}))
+	if !strings.Contains(svg, "&lt;hi&gt;</tspan>") || !strings.Contains(svg, ">there</tspan>") {
+		t.Errorf("expected escaped text split into lines, got:\n%s", svg)
+	}
+	if strings.Count(svg, "<path ") != 1 {
+		t.Errorf("expected the arrow to be drawn as a path, got:\n%s", svg)
+	}
+}
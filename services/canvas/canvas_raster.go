@@ -0,0 +1,407 @@
+package canvas_service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Rows and edge pixels stroked per image at most, far more than a drawing needs but keeps a crafted one from tying up the server.
+// Strokes past it are left out of the image
+const strokeWorkBudget = 1 << 23
+
+type RasterOptions struct {
+	Crop    bool    // Fit the image to the pieces instead of the whole board from the origin
+	Padding float64 // Space around the pieces when cropping, in canvas units
+	Scale   float64 // Pixels per canvas unit
+	MaxSize int     // Longest side of the image in pixels, the scale is lowered to fit
+}
+
+// Renders the canvas to an image, pieces are stroked with round caps and joins like the SVG export
+func Rasterize(canvasData CanvasData, opts RasterOptions) *image.NRGBA {
+	bounds := canvasData.ExportBounds(opts.Crop, opts.Padding)
+
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	if opts.MaxSize > 0 {
+		scale = math.Min(scale, float64(opts.MaxSize)/math.Max(bounds.Width(), bounds.Height()))
+	}
+
+	width := max(1, int(math.Ceil(bounds.Width()*scale)))
+	height := max(1, int(math.Ceil(bounds.Height()*scale)))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	if canvasData.BackgroundColor != "" {
+		background := parseColor(canvasData.BackgroundColor)
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = background.R, background.G, background.B, background.A
+		}
+	}
+
+	if canvasData.PiecesManager == nil {
+		return img
+	}
+
+	// Canvas units to pixels
+	toPixels := func(pt Point) Point {
+		return Point{X: (pt.X - bounds.Left) * scale, Y: (pt.Y - bounds.Top) * scale}
+	}
+
+	budget := strokeWorkBudget
+	for _, piece := range canvasData.PiecesManager.VisiblePieces() {
+		if budget <= 0 {
+			break
+		}
+		// Text and images have no outline, the PNG export draws strokes only
+		outline := piece.Outline()
+		if outline == "" {
+			continue
+		}
+		subpaths, err := ParsePath(outline)
+		if err != nil {
+			continue // Skip pieces a client sent garbage for rather than failing the whole export
+		}
+
+		stroke := color.NRGBA{A: 255}
+		strokeWidth := 1.0
+		if piece.Settings != nil {
+			if piece.Settings.Coloer != "" {
+				stroke = parseColor(piece.Settings.Coloer)
+			}
+			strokeWidth = float64(piece.Settings.Size)
+		}
+
+		m := piece.Move
+		if m != (DOMMatrixs{}) {
+			strokeWidth *= math.Sqrt(math.Abs(m.A*m.D - m.B*m.C))
+		}
+		strokeWidth *= scale
+
+		for i, subpath := range subpaths {
+			for j, pt := range subpath {
+				subpaths[i][j] = toPixels(m.Apply(pt))
+			}
+		}
+		strokeSubpaths(img, subpaths, strokeWidth, stroke, &budget)
+	}
+
+	return img
+}
+
+// Renders the canvas as a PNG
+func RenderPNG(canvasData CanvasData, opts RasterOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, Rasterize(canvasData, opts))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// A small PNG preview of the pieces, size is the longest side in pixels
+func RenderThumbnail(canvasData CanvasData, size int) ([]byte, error) {
+	bounds := canvasData.ExportBounds(true, 0)
+	longest := math.Max(bounds.Width(), bounds.Height())
+	padding := longest * 0.05
+	return RenderPNG(canvasData, RasterOptions{
+		Crop:    true,
+		Padding: padding,
+		Scale:   float64(size) / (longest + 2*padding),
+		MaxSize: size,
+	})
+}
+
+// Strokes the polylines in pixel space, anti-aliased by each pixel's distance to the nearest segment.
+// Coverage is worked out for the whole piece before blending, so overlapping segments of a translucent stroke don't darken.
+// Rows are filled a span at a time, only pixels on the edge of a segment have their distance worked out, so a segment costs
+// the rows and edge pixels it touches rather than its whole box, and a thick stroke made of many short segments stays cheap.
+// Each row and edge pixel is taken off budget, segments are skipped once it runs out.
+func strokeSubpaths(img *image.NRGBA, subpaths [][]Point, width float64, stroke color.NRGBA, budget *int) {
+	opacity := math.Min(width, 1) // Hairlines are drawn a pixel wide but fainter
+	// Wider than the image covers the image either way
+	bounds := img.Bounds()
+	width = math.Min(width, math.Hypot(float64(bounds.Dx()), float64(bounds.Dy())))
+	halfWidth := math.Max(width, 1) / 2
+
+	// Only the area the piece touches needs a coverage buffer
+	area := image.Rectangle{}
+	for _, subpath := range subpaths {
+		for _, pt := range subpath {
+			r := image.Rect(
+				int(math.Floor(pt.X-halfWidth-1)), int(math.Floor(pt.Y-halfWidth-1)),
+				int(math.Ceil(pt.X+halfWidth+1)), int(math.Ceil(pt.Y+halfWidth+1)),
+			)
+			area = area.Union(r)
+		}
+	}
+	area = area.Intersect(bounds)
+	if area.Empty() {
+		return
+	}
+
+	coverage := make([]float32, area.Dx()*area.Dy())
+	solid := make([]spans, area.Dy()) // Per row, pixels fully covered by some segment
+	outer, inner := halfWidth+0.5, halfWidth-0.5
+	for _, subpath := range subpaths {
+		if len(subpath) == 1 {
+			subpath = append(subpath, subpath[0]) // A lone point is drawn as a dot
+		}
+		for i := 1; i < len(subpath) && *budget > 0; i++ {
+			a, b := subpath[i-1], subpath[i]
+			top := max(area.Min.Y, int(math.Floor(math.Min(a.Y, b.Y)-outer)))
+			bottom := min(area.Max.Y, int(math.Ceil(math.Max(a.Y, b.Y)+outer)))
+
+			for y := top; y < bottom; y++ {
+				if solid[y-area.Min.Y].covers(area) {
+					continue // Nothing left to draw on this row
+				}
+				*budget--
+				center := float64(y) + 0.5
+				touched, ok := capsuleRow(a, b, outer, center).pixels(area)
+				if !ok {
+					continue
+				}
+				full, ok := capsuleRow(a, b, inner, center).pixels(area)
+				if !ok || inner <= 0 {
+					full = span{from: touched.from, to: touched.from}
+				}
+				solid[y-area.Min.Y].add(full)
+
+				// Edge pixels either side of the fully covered ones
+				row := coverage[(y-area.Min.Y)*area.Dx():]
+				for _, edge := range []span{{touched.from, full.from}, {full.to, touched.to}} {
+					*budget -= edge.to - edge.from
+					for x := edge.from; x < edge.to; x++ {
+						if c := float32(outer - distanceToSegment(Point{X: float64(x) + 0.5, Y: center}, a, b)); c > row[x-area.Min.X] {
+							row[x-area.Min.X] = min(c, 1)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for y := range solid {
+		row := coverage[y*area.Dx():]
+		for _, s := range solid[y].merged() {
+			for x := s.from; x < s.to; x++ {
+				row[x-area.Min.X] = 1
+			}
+		}
+	}
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			c := coverage[(y-area.Min.Y)*area.Dx()+(x-area.Min.X)]
+			if c > 0 {
+				blend(img, x, y, stroke, float64(c)*opacity)
+			}
+		}
+	}
+}
+
+// Pixels from up to but not including to, or a range of x in pixel space
+type span struct {
+	from int
+	to   int
+}
+
+// A row's spans, merged every so often so a row crossed by many segments keeps few of them
+type spans struct {
+	list   []span
+	merges int // Length of list after the last merge
+}
+
+func (ss *spans) add(s span) {
+	if s.from >= s.to {
+		return
+	}
+	ss.list = append(ss.list, s)
+	if len(ss.list) > 2*ss.merges+16 {
+		ss.merged()
+	}
+}
+
+// Joins overlapping spans so each pixel is filled once, in order
+func (ss *spans) merged() []span {
+	slices.SortFunc(ss.list, func(a, b span) int { return a.from - b.from })
+	merged := ss.list[:0]
+	for _, s := range ss.list {
+		if n := len(merged); n > 0 && s.from <= merged[n-1].to {
+			merged[n-1].to = max(merged[n-1].to, s.to)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	ss.list = merged
+	ss.merges = len(merged)
+	return merged
+}
+
+// Whether the whole row of area is already covered, only known once merged
+func (ss *spans) covers(area image.Rectangle) bool {
+	return ss.merges == 1 && ss.list[0].from <= area.Min.X && ss.list[0].to >= area.Max.X
+}
+
+// Where the horizontal line at y crosses the points within radius of the segment, as a range of x
+type rowRange struct {
+	from, to float64
+	ok       bool
+}
+
+// The capsule is convex, so the line crosses it once, somewhere within the crossings of its two end discs and the band between them
+func capsuleRow(a Point, b Point, radius float64, y float64) rowRange {
+	r := rowRange{from: math.Inf(1), to: math.Inf(-1)}
+	if radius <= 0 {
+		return r
+	}
+	include := func(from float64, to float64) {
+		if from <= to {
+			r.from, r.to, r.ok = math.Min(r.from, from), math.Max(r.to, to), true
+		}
+	}
+
+	for _, end := range []Point{a, b} {
+		if dy := y - end.Y; math.Abs(dy) <= radius {
+			half := math.Sqrt(radius*radius - dy*dy)
+			include(end.X-half, end.X+half)
+		}
+	}
+
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := math.Hypot(dx, dy)
+	if length > 0 {
+		// Along the segment from 0 to length, and across it within radius, both are linear in x
+		along := linearRange((y-a.Y)*dy/length, dx/length, 0, length)
+		across := linearRange((y-a.Y)*dx/length, -dy/length, -radius, radius)
+		include(math.Max(along.from+a.X, across.from+a.X), math.Min(along.to+a.X, across.to+a.X))
+	}
+
+	return r
+}
+
+// The x where lo <= offset + slope*x <= hi
+func linearRange(offset float64, slope float64, lo float64, hi float64) rowRange {
+	if slope == 0 {
+		if offset < lo || offset > hi {
+			return rowRange{from: math.Inf(1), to: math.Inf(-1)}
+		}
+		return rowRange{from: math.Inf(-1), to: math.Inf(1), ok: true}
+	}
+	from, to := (lo-offset)/slope, (hi-offset)/slope
+	if from > to {
+		from, to = to, from
+	}
+	return rowRange{from: from, to: to, ok: true}
+}
+
+// The pixels within area whose centres are in the range
+func (r rowRange) pixels(area image.Rectangle) (span, bool) {
+	if !r.ok {
+		return span{}, false
+	}
+	s := span{
+		from: max(area.Min.X, int(math.Ceil(math.Max(r.from-0.5, float64(area.Min.X-1))))),
+		to:   min(area.Max.X, int(math.Floor(math.Min(r.to-0.5, float64(area.Max.X))))+1),
+	}
+	return s, s.from < s.to
+}
+
+func distanceToSegment(p Point, a Point, b Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lengthSquared := dx*dx + dy*dy
+	t := 0.0
+	if lengthSquared > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/lengthSquared))
+	}
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
+
+// Source over compositing of a non premultiplied colour
+func blend(img *image.NRGBA, x int, y int, src color.NRGBA, coverage float64) {
+	i := img.PixOffset(x, y)
+	srcA := float64(src.A) / 255 * coverage
+	dstA := float64(img.Pix[i+3]) / 255
+	outA := srcA + dstA*(1-srcA)
+	if outA == 0 {
+		return
+	}
+
+	channel := func(s uint8, d uint8) uint8 {
+		return uint8(math.Round((float64(s)*srcA + float64(d)*dstA*(1-srcA)) / outA))
+	}
+	img.Pix[i] = channel(src.R, img.Pix[i])
+	img.Pix[i+1] = channel(src.G, img.Pix[i+1])
+	img.Pix[i+2] = channel(src.B, img.Pix[i+2])
+	img.Pix[i+3] = uint8(math.Round(outA * 255))
+}
+
+var namedColors = map[string]color.NRGBA{
+	"black":       {A: 255},
+	"white":       {R: 255, G: 255, B: 255, A: 255},
+	"red":         {R: 255, A: 255},
+	"green":       {G: 128, A: 255},
+	"blue":        {B: 255, A: 255},
+	"transparent": {},
+}
+
+// Parses the CSS colours clients use, hex and rgb(a) notation plus a few names, anything else is black
+func parseColor(s string) color.NRGBA {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c
+	}
+
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) == 3 || len(hex) == 4 {
+			expanded := ""
+			for _, r := range hex {
+				expanded += string(r) + string(r)
+			}
+			hex = expanded
+		}
+		if len(hex) == 6 {
+			hex += "ff"
+		}
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil && len(hex) == 8 {
+			return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+		}
+		return color.NRGBA{A: 255}
+	}
+
+	if args, ok := strings.CutPrefix(s, "rgb"); ok {
+		args = strings.TrimPrefix(args, "a")
+		args = strings.TrimSuffix(strings.TrimPrefix(args, "("), ")")
+		parts := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) == 3 || len(parts) == 4 {
+			c := color.NRGBA{A: 255}
+			channels := []*uint8{&c.R, &c.G, &c.B}
+			for i, channel := range channels {
+				v, err := strconv.ParseFloat(parts[i], 64)
+				if err != nil {
+					return color.NRGBA{A: 255}
+				}
+				*channel = uint8(math.Max(0, math.Min(255, v)))
+			}
+			if len(parts) == 4 {
+				a, err := strconv.ParseFloat(strings.TrimSuffix(parts[3], "%"), 64)
+				if err == nil {
+					if strings.HasSuffix(parts[3], "%") {
+						a /= 100
+					}
+					c.A = uint8(math.Round(math.Max(0, math.Min(1, a)) * 255))
+				}
+			}
+			return c
+		}
+	}
+
+	return color.NRGBA{A: 255}
+}
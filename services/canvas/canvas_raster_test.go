@@ -0,0 +1,114 @@
+package canvas_service
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	// Compact syntax: numbers run together, implicit linetos after a moveto, relative commands and arc flags without separators
+	subpaths, err := ParsePath("M1.5.5-2,3h1v1z m1e1 0 l1 1a1 1 0 011 1")
+	if err != nil {
+		t.Fatalf("failed to parse path: %v", err)
+	}
+	if len(subpaths) != 2 {
+		t.Fatalf("expected 2 subpaths, got %d", len(subpaths))
+	}
+
+	expected := []Point{{1.5, 0.5}, {-2, 3}, {-1, 3}, {-1, 4}, {1.5, 0.5}}
+	for i, pt := range expected {
+		if subpaths[0][i] != pt {
+			t.Errorf("expected point %d to be %v, got %v", i, pt, subpaths[0][i])
+		}
+	}
+
+	// The second subpath starts relative to where the first one closed, and the arc ends where it says it does
+	if subpaths[1][0] != (Point{11.5, 0.5}) {
+		t.Errorf("expected second subpath to start at {11.5 0.5}, got %v", subpaths[1][0])
+	}
+	end := subpaths[1][len(subpaths[1])-1]
+	if end.X < 13.499 || end.X > 13.501 || end.Y < 2.499 || end.Y > 2.501 {
+		t.Errorf("expected arc to end at {13.5 2.5}, got %v", end)
+	}
+
+	if _, err := ParsePath("10 10"); err == nil {
+		t.Errorf("expected path without a leading command to fail")
+	}
+}
+
+func TestRasterize(t *testing.T) {
+	left, right, top, bottom := 0.0, 20.0, 0.0, 20.0
+	canvasData := CanvasData{
+		BackgroundColor: "#ffffff",
+		PiecesManager: &PiecesManager{
+			LeftMost: &left, RightMost: &right, TopMost: &top, BottomMost: &bottom,
+			Pieces: []*PieceData{
+				{Path: "M 0 10 L 20 10", Settings: &PieceSettings{Size: 2, Coloer: "#ff0000"}},
+			},
+		},
+	}
+
+	img := Rasterize(canvasData, RasterOptions{Scale: 2})
+	if img.Bounds().Dx() != 40 || img.Bounds().Dy() != 40 {
+		t.Fatalf("expected a 40x40 image, got %v", img.Bounds())
+	}
+	if got := img.NRGBAAt(20, 20); got != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("expected the stroke to be red, got %v", got)
+	}
+	if got := img.NRGBAAt(20, 5); got != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("expected the background to be white, got %v", got)
+	}
+
+	// The scale is lowered to fit the maximum size
+	img = Rasterize(canvasData, RasterOptions{Scale: 8, MaxSize: 10})
+	if img.Bounds().Dx() != 10 {
+		t.Errorf("expected the image to be scaled down to 10 pixels wide, got %d", img.Bounds().Dx())
+	}
+}
+
+func TestStrokeWorkIsBounded(t *testing.T) {
+	// A thick stroke of many short segments, each segment's box covers the whole image
+	subpaths := [][]Point{make([]Point, 0, 25000)}
+	for i := 0; i < 25000; i++ {
+		subpaths[0] = append(subpaths[0], Point{X: float64(i%2) * 256, Y: float64(i%256) + 0.5})
+	}
+	blue := color.NRGBA{B: 255, A: 255}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	budget := strokeWorkBudget
+	strokeSubpaths(img, subpaths, 1e6, blue, &budget)
+	for _, pt := range []image.Point{{0, 0}, {128, 128}, {255, 255}} {
+		if got := img.NRGBAAt(pt.X, pt.Y); got != blue {
+			t.Errorf("expected %v to be covered, got %v", pt, got)
+		}
+	}
+	// Rows already covered cost nothing, so most of the budget is left
+	if budget < strokeWorkBudget/2 {
+		t.Errorf("expected most of the budget to be left, %d of %d was used", strokeWorkBudget-budget, strokeWorkBudget)
+	}
+
+	// Nothing is drawn once the budget is spent
+	img = image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	budget = 0
+	strokeSubpaths(img, subpaths, 2, blue, &budget)
+	if got := img.NRGBAAt(0, 0); got != (color.NRGBA{}) {
+		t.Errorf("expected nothing to be drawn without budget, got %v", got)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	cases := map[string]color.NRGBA{
+		"#f00":                  {R: 255, A: 255},
+		"#00ff0080":             {G: 255, A: 128},
+		"rgba(0, 0, 255, 0.5)":  {B: 255, A: 128},
+		"rgb(10 20 30)":         {R: 10, G: 20, B: 30, A: 255},
+		"white":                 {R: 255, G: 255, B: 255, A: 255},
+		"definitely not colour": {A: 255},
+	}
+	for s, expected := range cases {
+		if got := parseColor(s); got != expected {
+			t.Errorf("parseColor(%q) = %v, expected %v", s, got, expected)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package canvas_service
 
 import (
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	service "qolboard-api/services"
+	"reflect"
 )
 
 const (
@@ -33,21 +37,31 @@ type RulerSettings struct {
 
 type PiecesManager struct {
 	Pieces     []*PieceData `json:"pieces"`
-	LeftMost   *float64     `json:"leftMost" binding:"required"`
-	RightMost  *float64     `json:"rightMost" binding:"required"`
-	TopMost    *float64     `json:"topMost" binding:"required"`
-	BottomMost *float64     `json:"bottomMost" binding:"required"`
+	Layers     []*Layer     `json:"layers"`   // Bottom layer first
+	LeftMost   *float64     `json:"leftMost"` // Bounds are worked out by the server, missing when there are no pieces
+	RightMost  *float64     `json:"rightMost"`
+	TopMost    *float64     `json:"topMost"`
+	BottomMost *float64     `json:"bottomMost"`
 }
 
 type PieceData struct {
+	ID       string         `json:"id"`      // Server assigned, stable across collaborators
+	LayerID  string         `json:"layerId"` // Pieces on unknown layers are moved to the bottom layer
+	Kind     string         `json:"kind"`    // One of PieceKinds, each kind has its own payload named after it
 	Settings *PieceSettings `json:"settings" binding:"required"`
-	Path     string         `json:"path" binding:"required"`
+	Path     string         `json:"path"` // Freehand SVG path data
 	Move     DOMMatrixs     `json:"move" binding:"required"`
 	// Pan        DOMMatrixs     `json:"pan" binding:"required"`
 	LeftMost   *float64 `json:"leftMost" binding:"required"`
 	RightMost  *float64 `json:"rightMost" binding:"required"`
 	TopMost    *float64 `json:"topMost" binding:"required"`
 	BottomMost *float64 `json:"bottomMost" binding:"required"`
+
+	Text      *TextData  `json:"text,omitempty"`
+	Rectangle *ShapeData `json:"rectangle,omitempty"`
+	Ellipse   *ShapeData `json:"ellipse,omitempty"`
+	Arrow     *ArrowData `json:"arrow,omitempty"`
+	Image     *ImageData `json:"image,omitempty"`
 }
 
 type DOMMatrixs struct {
@@ -85,9 +99,159 @@ func (canvasData *CanvasData) Scan(value any) error {
 		return fmt.Errorf("failed to scan CanvasData: %v", value)
 	}
 
-	return json.Unmarshal(bytes, canvasData)
+	err := json.Unmarshal(bytes, canvasData)
+	if err != nil {
+		return err
+	}
+
+	// Older canvases were stored before pieces had IDs and layers
+	canvasData.BackfillLayers()
+	return canvasData.BackfillPieceIDs()
 }
 
 func (c CanvasData) Value() (driver.Value, error) {
 	return json.Marshal(c)
 }
+
+// A copy which shares nothing with the original, pieces and settings included
+func (c CanvasData) DeepCopy() (CanvasData, error) {
+	var copied CanvasData
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		return copied, err
+	}
+	err = json.Unmarshal(bytes, &copied)
+	return copied, err
+}
+
+func NewPieceID() (string, error) {
+	return service.GenerateCode(16)
+}
+
+// Assigns an ID to every piece that does not have one, or whose ID is already taken by another piece.
+// The IDs are derived from the pieces, so reading the same stored canvas again gives its pieces the same IDs
+func (canvasData *CanvasData) BackfillPieceIDs() error {
+	if canvasData.PiecesManager == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(canvasData.PiecesManager.Pieces))
+	for _, piece := range canvasData.PiecesManager.Pieces {
+		if piece == nil {
+			continue
+		}
+		if piece.ID == "" || seen[piece.ID] {
+			id, err := derivePieceID(piece, seen)
+			if err != nil {
+				return err
+			}
+			piece.ID = id
+		}
+		seen[piece.ID] = true
+	}
+
+	return nil
+}
+
+// An ID in the same format as NewPieceID hashed from the piece, identical pieces are told apart by how many came before
+func derivePieceID(piece *PieceData, seen map[string]bool) (string, error) {
+	bytes, err := json.Marshal(piece)
+	if err != nil {
+		return "", err
+	}
+
+	for n := 0; ; n++ {
+		sum := sha256.Sum256(fmt.Appendf(bytes, "#%d", n))
+		id := base64.RawURLEncoding.EncodeToString(sum[:12])
+		if !seen[id] {
+			return id, nil
+		}
+	}
+}
+
+// Returns the index of the piece with the given ID, or -1 if it does not exist
+func (pm *PiecesManager) IndexOf(id string) int {
+	for i, piece := range pm.Pieces {
+		if piece != nil && piece.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+type CanvasDataDiff struct {
+	Fields        map[string]FieldChange `json:"fields"`
+	AddedPieces   []string               `json:"added_pieces"`
+	RemovedPieces []string               `json:"removed_pieces"`
+	ChangedPieces []string               `json:"changed_pieces"`
+}
+
+// Describes what changed going from one canvas data to another, pieces are matched by ID
+func Diff(from CanvasData, to CanvasData) CanvasDataDiff {
+	diff := CanvasDataDiff{
+		Fields:        make(map[string]FieldChange),
+		AddedPieces:   make([]string, 0),
+		RemovedPieces: make([]string, 0),
+		ChangedPieces: make([]string, 0),
+	}
+
+	if from.Name != to.Name {
+		diff.Fields["name"] = FieldChange{From: from.Name, To: to.Name}
+	}
+	if from.BackgroundColor != to.BackgroundColor {
+		diff.Fields["backgroundColor"] = FieldChange{From: from.BackgroundColor, To: to.BackgroundColor}
+	}
+	if !reflect.DeepEqual(from.PieceSettings, to.PieceSettings) {
+		diff.Fields["pieceSettings"] = FieldChange{From: from.PieceSettings, To: to.PieceSettings}
+	}
+	if from.RulerSettings != to.RulerSettings {
+		diff.Fields["rulerSettings"] = FieldChange{From: from.RulerSettings, To: to.RulerSettings}
+	}
+
+	fromPieces := piecesByID(from.PiecesManager)
+	toPieces := piecesByID(to.PiecesManager)
+
+	if from.PiecesManager != nil {
+		for _, piece := range from.PiecesManager.Pieces {
+			if piece == nil {
+				continue
+			}
+			if _, ok := toPieces[piece.ID]; !ok {
+				diff.RemovedPieces = append(diff.RemovedPieces, piece.ID)
+			}
+		}
+	}
+	if to.PiecesManager != nil {
+		for _, piece := range to.PiecesManager.Pieces {
+			if piece == nil {
+				continue
+			}
+			fromPiece, ok := fromPieces[piece.ID]
+			if !ok {
+				diff.AddedPieces = append(diff.AddedPieces, piece.ID)
+			} else if !reflect.DeepEqual(fromPiece, piece) {
+				diff.ChangedPieces = append(diff.ChangedPieces, piece.ID)
+			}
+		}
+	}
+
+	return diff
+}
+
+func piecesByID(pm *PiecesManager) map[string]*PieceData {
+	m := make(map[string]*PieceData)
+	if pm == nil {
+		return m
+	}
+	for _, piece := range pm.Pieces {
+		if piece != nil {
+			m[piece.ID] = piece
+		}
+	}
+	return m
+}
@@ -0,0 +1,125 @@
+package canvas_service
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestScanBackfillsTheSamePieceIDs(t *testing.T) {
+	stored := []byte(`{"name":"test","backgroundColor":"#ffffff","piecesManager":{"pieces":[
+		{"path":"M 0 0 L 10 10","settings":{"size":2,"color":"#000000"}},
+		{"path":"M 0 0 L 10 10","settings":{"size":2,"color":"#000000"}},
+		{"id":"kept","path":"M 5 5 L 10 10","settings":{"size":2,"color":"#000000"}},
+		{"id":"kept","path":"M 6 6 L 10 10","settings":{"size":2,"color":"#000000"}}
+	]}}`)
+
+	scan := func() []string {
+		t.Helper()
+		var canvasData CanvasData
+		err := canvasData.Scan(stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0)
+		for _, piece := range canvasData.PiecesManager.Pieces {
+			ids = append(ids, piece.ID)
+		}
+		return ids
+	}
+
+	first, second := scan(), scan()
+	seen := make(map[string]bool)
+	for i, id := range first {
+		if id == "" || seen[id] {
+			t.Errorf("expected piece %d to get an ID of its own, got %q in %v", i, id, first)
+		}
+		seen[id] = true
+		if second[i] != id {
+			t.Errorf("expected piece %d to get the same ID when read again, got %q and %q", i, id, second[i])
+		}
+	}
+	if first[2] != "kept" {
+		t.Errorf("expected a piece's own ID to be kept, got %q", first[2])
+	}
+}
+
+func TestDiff(t *testing.T) {
+	piece := func(id string, path string) *PieceData {
+		return &PieceData{ID: id, Path: path, Settings: &PieceSettings{Size: 2, Coloer: "#000000"}}
+	}
+	canvasData := func(pieces ...*PieceData) CanvasData {
+		return CanvasData{
+			Name:            "test",
+			BackgroundColor: "#ffffff",
+			PieceSettings:   &PieceSettings{Size: 2, Coloer: "#000000"},
+			PiecesManager:   &PiecesManager{Pieces: pieces},
+		}
+	}
+
+	renamed := canvasData()
+	renamed.Name = "renamed"
+	recoloured := canvasData()
+	recoloured.BackgroundColor = "#000000"
+	resized := canvasData()
+	resized.PieceSettings = &PieceSettings{Size: 4, Coloer: "#000000"}
+	rulers := canvasData()
+	rulers.RulerSettings.ShowLines = true
+
+	tests := []struct {
+		name    string
+		from    CanvasData
+		to      CanvasData
+		fields  []string
+		added   []string
+		removed []string
+		changed []string
+	}{
+		{"nothing changed", canvasData(piece("a", "M 0 0")), canvasData(piece("a", "M 0 0")), nil, nil, nil, nil},
+		{"same settings in another pointer", canvasData(), canvasData(), nil, nil, nil, nil},
+		{"renamed", canvasData(), renamed, []string{"name"}, nil, nil, nil},
+		{"background", canvasData(), recoloured, []string{"backgroundColor"}, nil, nil, nil},
+		{"piece settings", canvasData(), resized, []string{"pieceSettings"}, nil, nil, nil},
+		{"ruler settings", canvasData(), rulers, []string{"rulerSettings"}, nil, nil, nil},
+		{
+			"pieces added, removed and changed",
+			canvasData(piece("kept", "M 0 0"), piece("gone", "M 1 1"), piece("moved", "M 2 2")),
+			canvasData(piece("moved", "M 3 3"), piece("kept", "M 0 0"), piece("new", "M 4 4")),
+			nil, []string{"new"}, []string{"gone"}, []string{"moved"},
+		},
+		{"reordering is no change", canvasData(piece("a", "M 0 0"), piece("b", "M 1 1")), canvasData(piece("b", "M 1 1"), piece("a", "M 0 0")), nil, nil, nil, nil},
+		{"from no pieces manager", CanvasData{}, CanvasData{PiecesManager: &PiecesManager{Pieces: []*PieceData{piece("a", "M 0 0")}}}, nil, []string{"a"}, nil, nil},
+		{"to no pieces manager", CanvasData{PiecesManager: &PiecesManager{Pieces: []*PieceData{piece("a", "M 0 0"), nil}}}, CanvasData{}, nil, nil, []string{"a"}, nil},
+	}
+	for _, tc := range tests {
+		diff := Diff(tc.from, tc.to)
+
+		fields := make([]string, 0)
+		for field := range diff.Fields {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+		for _, check := range []struct {
+			what     string
+			got      []string
+			expected []string
+		}{
+			{"fields", fields, tc.fields},
+			{"added pieces", diff.AddedPieces, tc.added},
+			{"removed pieces", diff.RemovedPieces, tc.removed},
+			{"changed pieces", diff.ChangedPieces, tc.changed},
+		} {
+			if check.expected == nil {
+				check.expected = []string{}
+			}
+			if !reflect.DeepEqual(check.got, check.expected) {
+				t.Errorf("%s: expected %s %v, got %v", tc.name, check.what, check.expected, check.got)
+			}
+		}
+	}
+
+	diff := Diff(canvasData(), renamed)
+	if diff.Fields["name"] != (FieldChange{From: "test", To: "renamed"}) {
+		t.Errorf("expected the name change to go from test to renamed, got %+v", diff.Fields["name"])
+	}
+}
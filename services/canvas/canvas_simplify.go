@@ -0,0 +1,121 @@
+package canvas_service
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Simplifies path data with Ramer–Douglas–Peucker, dropping points closer than tolerance to the segment kept around them.
+// Curves and arcs are flattened first, so the result is straight segments with coordinates rounded to hundredths.
+// The original is returned when simplifying wouldn't make it any shorter
+func SimplifyPath(d string, tolerance float64) (string, error) {
+	subpaths, err := ParsePath(d)
+	if err != nil {
+		return d, err
+	}
+
+	var b strings.Builder
+	for _, subpath := range subpaths {
+		for i, pt := range simplifyPoints(subpath, tolerance) {
+			if i == 0 {
+				b.WriteByte('M')
+			} else {
+				b.WriteByte('L')
+			}
+			b.WriteString(formatCoord(pt.X))
+			b.WriteByte(' ')
+			b.WriteString(formatCoord(pt.Y))
+		}
+	}
+
+	if b.Len() >= len(d) {
+		return d, nil
+	}
+	return b.String(), nil
+}
+
+// Iterative so long strokes can't blow the stack
+func simplifyPoints(points []Point, tolerance float64) []Point {
+	if len(points) < 3 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	spans := [][2]int{{0, len(points) - 1}}
+	for len(spans) > 0 {
+		first, last := spans[len(spans)-1][0], spans[len(spans)-1][1]
+		spans = spans[:len(spans)-1]
+
+		furthest, index := tolerance, -1
+		for i := first + 1; i < last; i++ {
+			d := segmentDistance(points[i], points[first], points[last])
+			if d > furthest {
+				furthest, index = d, i
+			}
+		}
+		if index >= 0 {
+			keep[index] = true
+			spans = append(spans, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	simplified := make([]Point, 0, len(points))
+	for i, pt := range points {
+		if keep[i] {
+			simplified = append(simplified, pt)
+		}
+	}
+	return simplified
+}
+
+// Distance from pt to the segment from a to b, closed subpaths start and end at the same point
+func segmentDistance(pt Point, a Point, b Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return math.Hypot(pt.X-a.X, pt.Y-a.Y)
+	}
+	t := math.Max(0, math.Min(1, ((pt.X-a.X)*dx+(pt.Y-a.Y)*dy)/lengthSquared))
+	return math.Hypot(pt.X-(a.X+t*dx), pt.Y-(a.Y+t*dy))
+}
+
+func formatCoord(f float64) string {
+	f = math.Round(f*100) / 100
+	if f == 0 {
+		f = 0 // No "-0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Simplifies a freehand piece's path, keeping its bounds in step. False when there was nothing to simplify
+func (piece *PieceData) Simplify(tolerance float64) bool {
+	if tolerance <= 0 || piece.Kind != PieceKindPath {
+		return false
+	}
+	simplified, err := SimplifyPath(piece.Path, tolerance)
+	if err != nil || simplified == piece.Path {
+		return false
+	}
+	piece.Path = simplified
+	piece.SetBounds()
+	return true
+}
+
+// Simplifies every freehand piece, returns how many changed
+func (canvasData *CanvasData) SimplifyPaths(tolerance float64) int {
+	if canvasData.PiecesManager == nil {
+		return 0
+	}
+	simplified := 0
+	for _, piece := range canvasData.PiecesManager.Pieces {
+		if piece != nil && piece.Simplify(tolerance) {
+			simplified++
+		}
+	}
+	if simplified > 0 {
+		canvasData.PiecesManager.SetBounds()
+	}
+	return simplified
+}
@@ -0,0 +1,93 @@
+package canvas_service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSimplifyPathDropsPointsOnALine(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("M 0 0")
+	for x := 1; x <= 100; x++ {
+		fmt.Fprintf(&b, " L %d.000001 %d.000001", x, x)
+	}
+
+	simplified, err := SimplifyPath(b.String(), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simplified != "M0 0L100 100" {
+		t.Errorf("expected a single segment, got %q", simplified)
+	}
+}
+
+func TestSimplifyPathKeepsCorners(t *testing.T) {
+	simplified, err := SimplifyPath("M 0 0 L 5 0.1 L 10 0 L 10 10 L 0 10 Z M 50 50 L 60 60", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simplified != "M0 0L10 0L10 10L0 10L0 0M50 50L60 60" {
+		t.Errorf("expected the corners and every subpath to be kept, got %q", simplified)
+	}
+}
+
+func TestSimplifyPathStaysWithinTolerance(t *testing.T) {
+	// Freehand strokes are dense, the simplified path must stay close to every one of their points
+	var b strings.Builder
+	b.WriteString("M 0 0")
+	for x := 1.0; x <= 300; x++ {
+		fmt.Fprintf(&b, " L %.4f %.4f", x, 20*math.Sin(x/20))
+	}
+	original := b.String()
+	tolerance := 2.0
+	simplified, err := SimplifyPath(original, tolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, _ := ParsePath(original)
+	after, _ := ParsePath(simplified)
+	if len(after) != 1 || len(after[0]) >= len(before[0]) {
+		t.Fatalf("expected fewer points, got %d from %d", len(after[0]), len(before[0]))
+	}
+	for _, pt := range before[0] {
+		closest := math.Inf(1)
+		for i := 1; i < len(after[0]); i++ {
+			closest = math.Min(closest, segmentDistance(pt, after[0][i-1], after[0][i]))
+		}
+		if closest > tolerance+0.01 {
+			t.Fatalf("expected %+v to be within the tolerance, it is %v away", pt, closest)
+		}
+	}
+}
+
+func TestSimplifyPathKeepsShortOrInvalidPaths(t *testing.T) {
+	if simplified, _ := SimplifyPath("M0 0L1 1", 1); simplified != "M0 0L1 1" {
+		t.Errorf("expected a path that can't get shorter to be kept, got %q", simplified)
+	}
+	if _, err := SimplifyPath("M 0 0 L x", 1); err == nil {
+		t.Errorf("expected invalid path data to be an error")
+	}
+}
+
+func TestCanvasDataSimplifyPaths(t *testing.T) {
+	path := &PieceData{Kind: PieceKindPath, Path: "M 0 0 L 50 0.2 L 100 0"}
+	text := &PieceData{Kind: PieceKindText, Text: &TextData{Content: "hi", FontSize: 10}}
+	canvasData := CanvasData{PiecesManager: &PiecesManager{Pieces: []*PieceData{path, text}}}
+
+	if simplified := canvasData.SimplifyPaths(0); simplified != 0 {
+		t.Fatalf("expected no simplification without a tolerance, got %d", simplified)
+	}
+	if simplified := canvasData.SimplifyPaths(1); simplified != 1 {
+		t.Fatalf("expected only the path to be simplified, got %d", simplified)
+	}
+	if path.Path != "M0 0L100 0" {
+		t.Errorf("expected the middle point to be dropped, got %q", path.Path)
+	}
+	bounds, _ := path.Bounds()
+	if bounds != (Bounds{Left: 0, Right: 100, Top: 0, Bottom: 0}) {
+		t.Errorf("expected the bounds to follow the simplified path, got %+v", bounds)
+	}
+}
@@ -0,0 +1,194 @@
+package canvas_service
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// Side of a grid cell in canvas units, around a screen's worth so typical viewports touch a handful of cells
+	spatialCellSize = 512
+	// Pieces covering more cells than this are kept out of the grid and checked on every query instead
+	spatialMaxCellsPerPiece = 256
+)
+
+type spatialCell struct {
+	x int64
+	y int64
+}
+
+// A uniform grid over piece bounds, for finding the pieces in a viewport without looking at every piece.
+// Not safe for concurrent use, rooms guard theirs with the room's mu
+type SpatialIndex struct {
+	cells  map[spatialCell]map[string]struct{}
+	bounds map[string]Bounds
+	large  map[string]struct{} // Pieces too big for the grid
+	free   map[string]struct{} // Pieces without bounds, they could be anywhere so are always returned
+}
+
+func NewSpatialIndex() *SpatialIndex {
+	return &SpatialIndex{
+		cells:  make(map[spatialCell]map[string]struct{}),
+		bounds: make(map[string]Bounds),
+		large:  make(map[string]struct{}),
+		free:   make(map[string]struct{}),
+	}
+}
+
+// Indexes every piece of the pieces manager
+func (pm *PiecesManager) SpatialIndex() *SpatialIndex {
+	index := NewSpatialIndex()
+	if pm == nil {
+		return index
+	}
+	for _, piece := range pm.Pieces {
+		if piece != nil {
+			index.Insert(piece)
+		}
+	}
+	return index
+}
+
+// Adds the piece, or moves it when it is already indexed
+func (index *SpatialIndex) Insert(piece *PieceData) {
+	index.Remove(piece.ID)
+
+	b, ok := piece.Bounds()
+	if !ok || !b.finite() {
+		index.free[piece.ID] = struct{}{}
+		return
+	}
+	index.bounds[piece.ID] = b
+
+	if cellCount(b) > spatialMaxCellsPerPiece {
+		index.large[piece.ID] = struct{}{}
+		return
+	}
+	minCell, maxCell := cellRange(b)
+	for x := minCell.x; x <= maxCell.x; x++ {
+		for y := minCell.y; y <= maxCell.y; y++ {
+			c := spatialCell{x: x, y: y}
+			if index.cells[c] == nil {
+				index.cells[c] = make(map[string]struct{})
+			}
+			index.cells[c][piece.ID] = struct{}{}
+		}
+	}
+}
+
+func (index *SpatialIndex) Remove(id string) {
+	delete(index.free, id)
+	delete(index.large, id)
+
+	b, exists := index.bounds[id]
+	if !exists {
+		return
+	}
+	delete(index.bounds, id)
+
+	if cellCount(b) > spatialMaxCellsPerPiece {
+		return
+	}
+	minCell, maxCell := cellRange(b)
+	for x := minCell.x; x <= maxCell.x; x++ {
+		for y := minCell.y; y <= maxCell.y; y++ {
+			c := spatialCell{x: x, y: y}
+			delete(index.cells[c], id)
+			if len(index.cells[c]) == 0 {
+				delete(index.cells, c)
+			}
+		}
+	}
+}
+
+// IDs of the pieces whose bounds intersect the view, edges touching count
+func (index *SpatialIndex) Query(view Bounds) map[string]struct{} {
+	found := make(map[string]struct{})
+	for id := range index.free {
+		found[id] = struct{}{}
+	}
+
+	check := func(id string) {
+		if view.Intersects(index.bounds[id]) {
+			found[id] = struct{}{}
+		}
+	}
+	for id := range index.large {
+		check(id)
+	}
+
+	if !view.finite() || cellCount(view) > float64(len(index.cells)) {
+		// Looking at more cells than there are filled ones, cheaper to go through the filled ones
+		for _, ids := range index.cells {
+			for id := range ids {
+				check(id)
+			}
+		}
+		return found
+	}
+	minCell, maxCell := cellRange(view)
+	for x := minCell.x; x <= maxCell.x; x++ {
+		for y := minCell.y; y <= maxCell.y; y++ {
+			for id := range index.cells[spatialCell{x: x, y: y}] {
+				check(id)
+			}
+		}
+	}
+	return found
+}
+
+// The pieces in the view, in drawing order
+func (pm *PiecesManager) PiecesIn(index *SpatialIndex, view Bounds) []*PieceData {
+	pieces := make([]*PieceData, 0)
+	if pm == nil {
+		return pieces
+	}
+
+	found := index.Query(view)
+	for _, piece := range pm.Pieces {
+		if piece == nil {
+			continue
+		}
+		if _, ok := found[piece.ID]; ok {
+			pieces = append(pieces, piece)
+		}
+	}
+	return pieces
+}
+
+var ErrInvalidView = errors.New("view must be finite with left <= right and top <= bottom")
+
+// Checks a requested view is a finite rectangle
+func (b Bounds) ValidateView() error {
+	if !b.finite() || b.Left > b.Right || b.Top > b.Bottom {
+		return ErrInvalidView
+	}
+	return nil
+}
+
+func (b Bounds) Intersects(other Bounds) bool {
+	return b.Left <= other.Right && other.Left <= b.Right && b.Top <= other.Bottom && other.Top <= b.Bottom
+}
+
+func (b Bounds) finite() bool {
+	for _, v := range []float64{b.Left, b.Right, b.Top, b.Bottom} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Counted in floats, huge bounds would overflow integers
+func cellCount(b Bounds) float64 {
+	wide := math.Floor(b.Right/spatialCellSize) - math.Floor(b.Left/spatialCellSize) + 1
+	high := math.Floor(b.Bottom/spatialCellSize) - math.Floor(b.Top/spatialCellSize) + 1
+	return max(wide, 0) * max(high, 0)
+}
+
+func cellRange(b Bounds) (spatialCell, spatialCell) {
+	toCell := func(v float64) int64 {
+		return int64(math.Floor(v / spatialCellSize))
+	}
+	return spatialCell{x: toCell(b.Left), y: toCell(b.Top)}, spatialCell{x: toCell(b.Right), y: toCell(b.Bottom)}
+}
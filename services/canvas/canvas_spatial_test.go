@@ -0,0 +1,64 @@
+package canvas_service
+
+import (
+	"slices"
+	"testing"
+)
+
+func boxPiece(id string, left float64, top float64, right float64, bottom float64) *PieceData {
+	return &PieceData{ID: id, LeftMost: &left, RightMost: &right, TopMost: &top, BottomMost: &bottom}
+}
+
+func ids(pieces []*PieceData) []string {
+	ids := make([]string, len(pieces))
+	for i, piece := range pieces {
+		ids[i] = piece.ID
+	}
+	return ids
+}
+
+func TestPiecesIn(t *testing.T) {
+	pm := &PiecesManager{
+		Pieces: []*PieceData{
+			boxPiece("far", 10000, 10000, 10010, 10010),
+			boxPiece("negative", -600, -600, -500, -500),
+			boxPiece("huge", -1e6, -1e6, 1e6, 1e6),
+			boxPiece("origin", 0, 0, 10, 10),
+			{ID: "unbounded", Path: "not a path"},
+			boxPiece("edge", 100, 0, 200, 10),
+		},
+	}
+	index := pm.SpatialIndex()
+
+	tests := []struct {
+		view     Bounds
+		expected []string
+	}{
+		{Bounds{Left: 0, Top: 0, Right: 100, Bottom: 100}, []string{"huge", "origin", "unbounded", "edge"}},
+		{Bounds{Left: -550, Top: -550, Right: -540, Bottom: -540}, []string{"negative", "huge", "unbounded"}},
+		{Bounds{Left: 9000, Top: 9000, Right: 20000, Bottom: 20000}, []string{"far", "huge", "unbounded"}},
+		{Bounds{Left: 2e6, Top: 2e6, Right: 3e6, Bottom: 3e6}, []string{"unbounded"}},
+		{Bounds{Left: -1e9, Top: -1e9, Right: 1e9, Bottom: 1e9}, []string{"far", "negative", "huge", "origin", "unbounded", "edge"}},
+	}
+	for _, test := range tests {
+		got := ids(pm.PiecesIn(index, test.view))
+		if !slices.Equal(got, test.expected) {
+			t.Errorf("view %+v: expected %v, got %v", test.view, test.expected, got)
+		}
+	}
+
+	// Moving a piece moves it in the index, removing it takes it out
+	pm.Pieces[3] = boxPiece("origin", 10000, 10000, 10001, 10001)
+	index.Insert(pm.Pieces[3])
+	index.Remove("far")
+	pm.Pieces = pm.Pieces[1:]
+
+	got := ids(pm.PiecesIn(index, Bounds{Left: 9000, Top: 9000, Right: 20000, Bottom: 20000}))
+	if !slices.Equal(got, []string{"huge", "origin", "unbounded"}) {
+		t.Errorf("expected the moved piece in its new place, got %v", got)
+	}
+	got = ids(pm.PiecesIn(index, Bounds{Left: 0, Top: 0, Right: 50, Bottom: 50}))
+	if !slices.Equal(got, []string{"huge", "unbounded"}) {
+		t.Errorf("expected the moved piece gone from its old place, got %v", got)
+	}
+}
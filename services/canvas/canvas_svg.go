@@ -0,0 +1,234 @@
+package canvas_service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type SVGOptions struct {
+	Crop    bool    // Fit the document to the pieces instead of the whole board from the origin
+	Padding float64 // Space around the pieces when cropping
+}
+
+type Bounds struct {
+	Left   float64
+	Right  float64
+	Top    float64
+	Bottom float64
+}
+
+func (b Bounds) Width() float64 {
+	return b.Right - b.Left
+}
+
+func (b Bounds) Height() float64 {
+	return b.Bottom - b.Top
+}
+
+// The area covered by the pieces, from the pieces manager's bounds or worked out from the pieces when those are missing
+func (pm *PiecesManager) Bounds() (Bounds, bool) {
+	if pm == nil {
+		return Bounds{}, false
+	}
+	if pm.LeftMost != nil && pm.RightMost != nil && pm.TopMost != nil && pm.BottomMost != nil {
+		return Bounds{Left: *pm.LeftMost, Right: *pm.RightMost, Top: *pm.TopMost, Bottom: *pm.BottomMost}, true
+	}
+
+	bounds := Bounds{Left: math.Inf(1), Right: math.Inf(-1), Top: math.Inf(1), Bottom: math.Inf(-1)}
+	found := false
+	for _, piece := range pm.Pieces {
+		if piece == nil {
+			continue
+		}
+		pieceBounds, ok := piece.Bounds()
+		if !ok {
+			continue
+		}
+		bounds.Left = math.Min(bounds.Left, pieceBounds.Left)
+		bounds.Right = math.Max(bounds.Right, pieceBounds.Right)
+		bounds.Top = math.Min(bounds.Top, pieceBounds.Top)
+		bounds.Bottom = math.Max(bounds.Bottom, pieceBounds.Bottom)
+		found = true
+	}
+	if !found {
+		return Bounds{}, false
+	}
+	return bounds, true
+}
+
+// The area covered by the piece, from its stored bounds or worked out from its shape when those are missing
+func (piece *PieceData) Bounds() (Bounds, bool) {
+	if piece.LeftMost != nil && piece.RightMost != nil && piece.TopMost != nil && piece.BottomMost != nil {
+		return Bounds{Left: *piece.LeftMost, Right: *piece.RightMost, Top: *piece.TopMost, Bottom: *piece.BottomMost}, true
+	}
+
+	subpaths, err := ParsePath(piece.extentPath())
+	if err != nil {
+		return Bounds{}, false
+	}
+	bounds := Bounds{Left: math.Inf(1), Right: math.Inf(-1), Top: math.Inf(1), Bottom: math.Inf(-1)}
+	found := false
+	for _, subpath := range subpaths {
+		for _, pt := range subpath {
+			pt = piece.Move.Apply(pt)
+			bounds.Left = math.Min(bounds.Left, pt.X)
+			bounds.Right = math.Max(bounds.Right, pt.X)
+			bounds.Top = math.Min(bounds.Top, pt.Y)
+			bounds.Bottom = math.Max(bounds.Bottom, pt.Y)
+			found = true
+		}
+	}
+	return bounds, found
+}
+
+// Works out the piece's bounds from its shape and transform and stores them, false when it draws nothing
+func (piece *PieceData) SetBounds() bool {
+	piece.LeftMost, piece.RightMost, piece.TopMost, piece.BottomMost = nil, nil, nil, nil
+	bounds, ok := piece.Bounds()
+	if !ok {
+		return false
+	}
+	piece.LeftMost, piece.RightMost, piece.TopMost, piece.BottomMost = &bounds.Left, &bounds.Right, &bounds.Top, &bounds.Bottom
+	return true
+}
+
+// Works out the bounds of all pieces and stores them, pieces must already have theirs
+func (pm *PiecesManager) SetBounds() {
+	pm.LeftMost, pm.RightMost, pm.TopMost, pm.BottomMost = nil, nil, nil, nil
+	bounds, ok := pm.Bounds()
+	if !ok {
+		return
+	}
+	pm.LeftMost, pm.RightMost, pm.TopMost, pm.BottomMost = &bounds.Left, &bounds.Right, &bounds.Top, &bounds.Bottom
+}
+
+// The area an export covers, at least one unit wide and high so the document is always valid
+func (canvasData CanvasData) ExportBounds(crop bool, padding float64) Bounds {
+	bounds, ok := canvasData.PiecesManager.Bounds()
+	if !crop {
+		// The whole board from the origin, so exports of the same canvas line up with each other
+		bounds = Bounds{
+			Left:   math.Min(0, bounds.Left),
+			Right:  math.Max(0, bounds.Right),
+			Top:    math.Min(0, bounds.Top),
+			Bottom: math.Max(0, bounds.Bottom),
+		}
+	} else if ok {
+		bounds = Bounds{
+			Left:   bounds.Left - padding,
+			Right:  bounds.Right + padding,
+			Top:    bounds.Top - padding,
+			Bottom: bounds.Bottom + padding,
+		}
+	}
+
+	bounds.Right = math.Max(bounds.Right, bounds.Left+1)
+	bounds.Bottom = math.Max(bounds.Bottom, bounds.Top+1)
+	return bounds
+}
+
+// Renders the canvas as a standalone SVG document
+func RenderSVG(canvasData CanvasData, opts SVGOptions) []byte {
+	bounds := canvasData.ExportBounds(opts.Crop, opts.Padding)
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="%s %s %s %s">`+"\n",
+		formatFloat(bounds.Width()), formatFloat(bounds.Height()),
+		formatFloat(bounds.Left), formatFloat(bounds.Top), formatFloat(bounds.Width()), formatFloat(bounds.Height()))
+
+	buf.WriteString("<title>")
+	xml.EscapeText(&buf, []byte(canvasData.Name))
+	buf.WriteString("</title>\n")
+
+	if canvasData.BackgroundColor != "" {
+		fmt.Fprintf(&buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+			formatFloat(bounds.Left), formatFloat(bounds.Top), formatFloat(bounds.Width()), formatFloat(bounds.Height()),
+			escapeAttr(canvasData.BackgroundColor))
+	}
+
+	if canvasData.PiecesManager != nil {
+		for _, piece := range canvasData.PiecesManager.VisiblePieces() {
+			writePieceSVG(&buf, piece)
+		}
+	}
+
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+func writePieceSVG(buf *bytes.Buffer, piece *PieceData) {
+	stroke := "#000000"
+	strokeWidth := 1
+	if piece.Settings != nil {
+		if piece.Settings.Coloer != "" {
+			stroke = piece.Settings.Coloer
+		}
+		strokeWidth = piece.Settings.Size
+	}
+
+	switch {
+	case piece.Kind == PieceKindText && piece.Text != nil:
+		t := piece.Text
+		fmt.Fprintf(buf, `<text x="%s" y="%s" font-size="%s" fill="%s" dominant-baseline="hanging" xml:space="preserve"`,
+			formatFloat(t.X), formatFloat(t.Y), formatFloat(t.FontSize), escapeAttr(stroke))
+		writeTransform(buf, piece.Move)
+		buf.WriteString(">")
+		// One tspan per line, SVG text doesn't break on newlines
+		for i, line := range strings.Split(t.Content, "\n") {
+			dy := "0"
+			if i > 0 {
+				dy = "1.2em"
+			}
+			fmt.Fprintf(buf, `<tspan x="%s" dy="%s">`, formatFloat(t.X), dy)
+			xml.EscapeText(buf, []byte(line))
+			buf.WriteString("</tspan>")
+		}
+		buf.WriteString("</text>\n")
+
+	default:
+		// Images aren't embedded yet, without an outline they're left out
+		outline := piece.Outline()
+		if outline == "" {
+			return
+		}
+		fmt.Fprintf(buf, `<path d="%s" fill="none" stroke="%s" stroke-width="%d" stroke-linecap="round" stroke-linejoin="round"`,
+			escapeAttr(outline), escapeAttr(stroke), strokeWidth)
+		writeTransform(buf, piece.Move)
+		buf.WriteString("/>\n")
+	}
+}
+
+func writeTransform(buf *bytes.Buffer, m DOMMatrixs) {
+	if !m.isIdentity() {
+		fmt.Fprintf(buf, ` transform="matrix(%s %s %s %s %s %s)"`,
+			formatFloat(m.A), formatFloat(m.B), formatFloat(m.C), formatFloat(m.D), formatFloat(m.E), formatFloat(m.F))
+	}
+}
+
+// Pieces which were never moved have an identity matrix, or none at all
+func (m DOMMatrixs) isIdentity() bool {
+	return m == (DOMMatrixs{}) || (m.A == 1 && m.B == 0 && m.C == 0 && m.D == 1 && m.E == 0 && m.F == 0)
+}
+
+// Transforms a point by the matrix's 2D part
+func (m DOMMatrixs) Apply(pt Point) Point {
+	if m == (DOMMatrixs{}) {
+		return pt
+	}
+	return Point{X: m.A*pt.X + m.C*pt.Y + m.E, Y: m.B*pt.X + m.D*pt.Y + m.F}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func escapeAttr(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,356 @@
+package canvas_service
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrNoPiecesInSVG = errors.New("svg does not contain any supported shapes")
+
+// A 2D affine transform, in the same order as SVG's matrix(a b c d e f)
+type affine struct {
+	a, b, c, d, e, f float64
+}
+
+var identity = affine{a: 1, d: 1}
+
+// m then n, i.e. n is applied in m's coordinate system
+func (m affine) multiply(n affine) affine {
+	return affine{
+		a: m.a*n.a + m.c*n.b,
+		b: m.b*n.a + m.d*n.b,
+		c: m.a*n.c + m.c*n.d,
+		d: m.b*n.c + m.d*n.d,
+		e: m.a*n.e + m.c*n.f + m.e,
+		f: m.b*n.e + m.d*n.f + m.f,
+	}
+}
+
+func (m affine) domMatrix() DOMMatrixs {
+	return NewDOMMatrix(m.a, m.b, m.c, m.d, m.e, m.f)
+}
+
+// A 2D transform as the browser's DOMMatrix would hold it
+func NewDOMMatrix(a float64, b float64, c float64, d float64, e float64, f float64) DOMMatrixs {
+	return DOMMatrixs{
+		A: a, B: b, C: c, D: d, E: e, F: f,
+		M11: a, M12: b, M21: c, M22: d, M41: e, M42: f,
+		M33: 1, M44: 1,
+	}
+}
+
+// Presentation state inherited from enclosing groups
+type svgState struct {
+	transform   affine
+	stroke      string
+	fill        string
+	strokeWidth float64
+	hidden      bool // Inside an element which is never rendered directly, e.g. defs
+}
+
+// Elements whose children are only referenced from elsewhere, or not shown at all
+var nonRenderedSVGElements = []string{"defs", "clipPath", "mask", "pattern", "symbol", "marker"}
+
+// Reads an SVG document and converts its shapes into pieces, keeping their transforms, stroke colours and widths.
+// Supports path, line, polyline, polygon, rect, circle and ellipse elements, anything else is skipped.
+func ImportSVG(r io.Reader) (CanvasData, error) {
+	canvasData := CanvasData{
+		BackgroundColor: "#ffffff",
+		PieceSettings:   &PieceSettings{Size: 2, Coloer: "#000000"},
+		PiecesManager:   &PiecesManager{Pieces: make([]*PieceData, 0)},
+	}
+
+	decoder := xml.NewDecoder(r)
+	stack := []svgState{{transform: identity, stroke: "none", fill: "#000000", strokeWidth: 1}}
+	inTitle := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return canvasData, fmt.Errorf("invalid svg: %w", err)
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			attrs := svgAttrs(el)
+			state := stack[len(stack)-1]
+
+			if t, ok := attrs["transform"]; ok {
+				transform, err := parseTransform(t)
+				if err != nil {
+					return canvasData, err
+				}
+				state.transform = state.transform.multiply(transform)
+			}
+			if v, ok := attrs["stroke"]; ok {
+				state.stroke = v
+			}
+			if v, ok := attrs["fill"]; ok {
+				state.fill = v
+			}
+			if v, ok := attrs["stroke-width"]; ok {
+				if width, err := parseLength(v); err == nil {
+					state.strokeWidth = width
+				}
+			}
+			if slices.Contains(nonRenderedSVGElements, el.Name.Local) || attrs["display"] == "none" {
+				state.hidden = true
+			}
+			stack = append(stack, state)
+
+			inTitle = el.Name.Local == "title" && canvasData.Name == ""
+
+			path, err := shapePath(el.Name.Local, attrs)
+			if err != nil {
+				return canvasData, fmt.Errorf("invalid %s element: %w", el.Name.Local, err)
+			}
+			if path != "" && !state.hidden {
+				piece, err := newImportedPiece(path, state)
+				if err != nil {
+					return canvasData, err
+				}
+				if piece != nil {
+					canvasData.PiecesManager.Pieces = append(canvasData.PiecesManager.Pieces, piece)
+				}
+			}
+
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			inTitle = false
+
+		case xml.CharData:
+			if inTitle {
+				canvasData.Name = strings.TrimSpace(string(el))
+			}
+		}
+	}
+
+	if len(canvasData.PiecesManager.Pieces) == 0 {
+		return canvasData, ErrNoPiecesInSVG
+	}
+
+	canvasData.PiecesManager.SetBounds()
+
+	return canvasData, nil
+}
+
+func newImportedPiece(path string, state svgState) (*PieceData, error) {
+	// Pieces are strokes, shapes which are only filled are outlined in their fill colour instead
+	colour := state.stroke
+	if colour == "none" || colour == "" {
+		colour = state.fill
+	}
+	if colour == "none" || colour == "" || strings.HasPrefix(colour, "url(") {
+		colour = "#000000"
+	}
+
+	id, err := NewPieceID()
+	if err != nil {
+		return nil, err
+	}
+
+	piece := &PieceData{
+		Kind:     PieceKindPath,
+		ID:       id,
+		Settings: &PieceSettings{Size: max(1, int(math.Round(state.strokeWidth))), Coloer: colour},
+		Path:     path,
+		Move:     state.transform.domMatrix(),
+	}
+
+	if !piece.SetBounds() {
+		return nil, nil // Nothing drawable, e.g. a path with no segments
+	}
+
+	return piece, nil
+}
+
+// Attributes by local name, with style declarations taking precedence like they do in browsers
+func svgAttrs(el xml.StartElement) map[string]string {
+	attrs := make(map[string]string, len(el.Attr))
+	for _, attr := range el.Attr {
+		attrs[attr.Name.Local] = strings.TrimSpace(attr.Value)
+	}
+	if style, ok := attrs["style"]; ok {
+		for _, declaration := range strings.Split(style, ";") {
+			name, value, found := strings.Cut(declaration, ":")
+			if found {
+				attrs[strings.TrimSpace(name)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return attrs
+}
+
+// Path data for a supported shape element, empty for anything else
+func shapePath(name string, attrs map[string]string) (string, error) {
+	num := func(key string) (float64, error) {
+		v, ok := attrs[key]
+		if !ok {
+			return 0, nil
+		}
+		return parseLength(v)
+	}
+	nums := func(keys ...string) ([]float64, error) {
+		values := make([]float64, len(keys))
+		for i, key := range keys {
+			v, err := num(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+
+	switch name {
+	case "path":
+		d := attrs["d"]
+		if _, err := ParsePath(d); err != nil {
+			return "", err
+		}
+		return d, nil
+
+	case "line":
+		v, err := nums("x1", "y1", "x2", "y2")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("M %s %s L %s %s", formatFloat(v[0]), formatFloat(v[1]), formatFloat(v[2]), formatFloat(v[3])), nil
+
+	case "polyline", "polygon":
+		fields := strings.FieldsFunc(attrs["points"], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+		})
+		if len(fields) < 2 {
+			return "", nil
+		}
+		var d strings.Builder
+		for i := 0; i+1 < len(fields); i += 2 {
+			x, errX := strconv.ParseFloat(fields[i], 64)
+			y, errY := strconv.ParseFloat(fields[i+1], 64)
+			if errX != nil || errY != nil {
+				return "", fmt.Errorf("invalid points %q", attrs["points"])
+			}
+			command := "L"
+			if i == 0 {
+				command = "M"
+			}
+			fmt.Fprintf(&d, "%s %s %s ", command, formatFloat(x), formatFloat(y))
+		}
+		if name == "polygon" {
+			d.WriteString("Z")
+		}
+		return strings.TrimSpace(d.String()), nil
+
+	case "rect":
+		v, err := nums("x", "y", "width", "height")
+		if err != nil {
+			return "", err
+		}
+		if v[2] <= 0 || v[3] <= 0 {
+			return "", nil
+		}
+		return fmt.Sprintf("M %s %s H %s V %s H %s Z",
+			formatFloat(v[0]), formatFloat(v[1]), formatFloat(v[0]+v[2]), formatFloat(v[1]+v[3]), formatFloat(v[0])), nil
+
+	case "circle", "ellipse":
+		var cx, cy, rx, ry float64
+		if name == "circle" {
+			v, err := nums("cx", "cy", "r")
+			if err != nil {
+				return "", err
+			}
+			cx, cy, rx, ry = v[0], v[1], v[2], v[2]
+		} else {
+			v, err := nums("cx", "cy", "rx", "ry")
+			if err != nil {
+				return "", err
+			}
+			cx, cy, rx, ry = v[0], v[1], v[2], v[3]
+		}
+		if rx <= 0 || ry <= 0 {
+			return "", nil
+		}
+		// Two half arcs, a single arc can't start and end on the same point
+		return fmt.Sprintf("M %s %s A %s %s 0 1 0 %s %s A %s %s 0 1 0 %s %s Z",
+			formatFloat(cx-rx), formatFloat(cy),
+			formatFloat(rx), formatFloat(ry), formatFloat(cx+rx), formatFloat(cy),
+			formatFloat(rx), formatFloat(ry), formatFloat(cx-rx), formatFloat(cy)), nil
+	}
+
+	return "", nil
+}
+
+// Parses a length in user units, "px" is accepted since it means the same thing
+func parseLength(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+}
+
+// Parses an SVG transform list, e.g. "translate(10 20) rotate(45)"
+func parseTransform(s string) (affine, error) {
+	result := identity
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		start := strings.Index(rest, "(")
+		end := strings.Index(rest, ")")
+		if start < 0 || end < start {
+			return identity, fmt.Errorf("invalid transform %q", s)
+		}
+		name := strings.TrimSpace(strings.Trim(rest[:start], ", "))
+		args := make([]float64, 0, 6)
+		for _, field := range strings.FieldsFunc(rest[start+1:end], func(r rune) bool { return r == ',' || r == ' ' }) {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return identity, fmt.Errorf("invalid transform %q", s)
+			}
+			args = append(args, v)
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+
+		arg := func(i int, fallback float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return fallback
+		}
+
+		var m affine
+		switch name {
+		case "matrix":
+			if len(args) != 6 {
+				return identity, fmt.Errorf("invalid transform %q", s)
+			}
+			m = affine{a: args[0], b: args[1], c: args[2], d: args[3], e: args[4], f: args[5]}
+		case "translate":
+			m = affine{a: 1, d: 1, e: arg(0, 0), f: arg(1, 0)}
+		case "scale":
+			sx := arg(0, 1)
+			m = affine{a: sx, d: arg(1, sx)}
+		case "rotate":
+			theta := arg(0, 0) * math.Pi / 180
+			cos, sin := math.Cos(theta), math.Sin(theta)
+			cx, cy := arg(1, 0), arg(2, 0)
+			m = affine{a: 1, d: 1, e: cx, f: cy}.
+				multiply(affine{a: cos, b: sin, c: -sin, d: cos}).
+				multiply(affine{a: 1, d: 1, e: -cx, f: -cy})
+		case "skewX":
+			m = affine{a: 1, c: math.Tan(arg(0, 0) * math.Pi / 180), d: 1}
+		case "skewY":
+			m = affine{a: 1, b: math.Tan(arg(0, 0) * math.Pi / 180), d: 1}
+		default:
+			return identity, fmt.Errorf("unsupported transform %q", name)
+		}
+		result = result.multiply(m)
+	}
+
+	return result, nil
+}
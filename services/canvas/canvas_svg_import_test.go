@@ -0,0 +1,62 @@
+package canvas_service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportSVG(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg">
+	<title>Sketch</title>
+	<defs><rect width="5" height="5"/></defs>
+	<g transform="translate(10,10) scale(2)" stroke="red" stroke-width="3">
+		<line x1="0" y1="0" x2="10" y2="0"/>
+		<circle cx="5" cy="5" r="5" fill="blue" stroke="none"/>
+	</g>
+	<rect x="1" y="2" width="3" height="4" style="stroke:#00f;stroke-width:2px"/>
+</svg>`
+
+	canvasData, err := ImportSVG(strings.NewReader(svg))
+	if err != nil {
+		t.Fatalf("failed to import svg: %v", err)
+	}
+	if canvasData.Name != "Sketch" {
+		t.Errorf("expected the title to become the name, got %q", canvasData.Name)
+	}
+
+	pieces := canvasData.PiecesManager.Pieces
+	if len(pieces) != 3 {
+		t.Fatalf("expected 3 pieces, shapes in defs are not drawn, got %d", len(pieces))
+	}
+
+	// Group transforms and styles are inherited
+	line := pieces[0]
+	if line.Path != "M 0 0 L 10 0" || line.Settings.Coloer != "red" || line.Settings.Size != 3 {
+		t.Errorf("unexpected line piece %q %+v", line.Path, *line.Settings)
+	}
+	if line.Move.A != 2 || line.Move.E != 10 || line.Move.F != 10 {
+		t.Errorf("expected the group transform on the line, got %+v", line.Move)
+	}
+	if *line.LeftMost != 10 || *line.RightMost != 30 || *line.TopMost != 10 || *line.BottomMost != 10 {
+		t.Errorf("expected the line's bounds to be transformed, got %v %v %v %v", *line.LeftMost, *line.RightMost, *line.TopMost, *line.BottomMost)
+	}
+
+	// Filled shapes without a stroke are outlined in their fill colour
+	if pieces[1].Settings.Coloer != "blue" {
+		t.Errorf("expected the circle to use its fill colour, got %q", pieces[1].Settings.Coloer)
+	}
+
+	// Style declarations are read like attributes
+	if pieces[2].Settings.Coloer != "#00f" || pieces[2].Settings.Size != 2 {
+		t.Errorf("expected the rect's style to be used, got %+v", *pieces[2].Settings)
+	}
+
+	pm := canvasData.PiecesManager
+	if *pm.LeftMost != 1 || *pm.RightMost != 30 || *pm.TopMost != 2 || *pm.BottomMost != 30 {
+		t.Errorf("unexpected canvas bounds %v %v %v %v", *pm.LeftMost, *pm.RightMost, *pm.TopMost, *pm.BottomMost)
+	}
+
+	if _, err := ImportSVG(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg"><text>hi</text></svg>`)); err != ErrNoPiecesInSVG {
+		t.Errorf("expected an svg without shapes to be rejected, got %v", err)
+	}
+}
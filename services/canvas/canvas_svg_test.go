@@ -0,0 +1,106 @@
+package canvas_service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderSVGViewBox(t *testing.T) {
+	canvasWith := func(paths ...string) CanvasData {
+		pieces := make([]*PieceData, 0)
+		for _, path := range paths {
+			pieces = append(pieces, &PieceData{Path: path, Settings: &PieceSettings{Size: 2, Coloer: "#000000"}})
+		}
+		return CanvasData{Name: "test", PiecesManager: &PiecesManager{Pieces: pieces}}
+	}
+
+	tests := []struct {
+		name     string
+		data     CanvasData
+		opts     SVGOptions
+		expected string
+	}{
+		{"whole board from the origin", canvasWith("M 10 20 L 30 40"), SVGOptions{}, `width="30" height="40" viewBox="0 0 30 40"`},
+		{"padding only applies when cropping", canvasWith("M 10 20 L 30 40"), SVGOptions{Padding: 5}, `width="30" height="40" viewBox="0 0 30 40"`},
+		{"board reaches past the origin", canvasWith("M -10 -5 L 10 5"), SVGOptions{}, `width="20" height="10" viewBox="-10 -5 20 10"`},
+		{"cropped to the pieces", canvasWith("M 10 20 L 30 40"), SVGOptions{Crop: true}, `width="20" height="20" viewBox="10 20 20 20"`},
+		{"cropped with padding", canvasWith("M 10 20 L 30 40", "M 15 25 L 20 30"), SVGOptions{Crop: true, Padding: 5}, `width="30" height="30" viewBox="5 15 30 30"`},
+		{"empty canvas is still a valid document", canvasWith(), SVGOptions{Crop: true, Padding: 5}, `width="1" height="1" viewBox="0 0 1 1"`},
+		{"a line is at least one unit high", canvasWith("M 10 20 L 30 20"), SVGOptions{Crop: true}, `width="20" height="1" viewBox="10 20 20 1"`},
+	}
+	for _, tc := range tests {
+		svg := string(RenderSVG(tc.data, tc.opts))
+		if !strings.Contains(svg, `<svg xmlns="http://www.w3.org/2000/svg" `+tc.expected+`>`) {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, svg)
+		}
+	}
+}
+
+func TestRenderSVGBackground(t *testing.T) {
+	canvasData := CanvasData{
+		Name:            "test",
+		BackgroundColor: "#ffeedd",
+		PiecesManager: &PiecesManager{Pieces: []*PieceData{
+			{Path: "M 10 20 L 30 40", Settings: &PieceSettings{Size: 2, Coloer: "#000000"}},
+		}},
+	}
+
+	// Covers the whole document, whatever area it shows
+	svg := string(RenderSVG(canvasData, SVGOptions{Crop: true, Padding: 5}))
+	if !strings.Contains(svg, `<rect x="5" y="15" width="30" height="30" fill="#ffeedd"/>`) {
+		t.Errorf("expected the background to fill the cropped document, got %s", svg)
+	}
+	svg = string(RenderSVG(canvasData, SVGOptions{}))
+	if !strings.Contains(svg, `<rect x="0" y="0" width="30" height="40" fill="#ffeedd"/>`) {
+		t.Errorf("expected the background to fill the whole board, got %s", svg)
+	}
+
+	canvasData.BackgroundColor = `"/><script/>`
+	svg = string(RenderSVG(canvasData, SVGOptions{}))
+	if strings.Contains(svg, "<script") {
+		t.Errorf("expected the background colour to be escaped, got %s", svg)
+	}
+
+	canvasData.BackgroundColor = ""
+	svg = string(RenderSVG(canvasData, SVGOptions{}))
+	if strings.Contains(svg, "<rect") {
+		t.Errorf("expected no background without a colour, got %s", svg)
+	}
+}
+
+func TestRenderSVGTransforms(t *testing.T) {
+	moved := DOMMatrixs{A: 2, B: 0, C: 0, D: 0.5, E: 5, F: -6.25}
+	tests := []struct {
+		name     string
+		piece    *PieceData
+		expected string
+	}{
+		{
+			"path never moved",
+			&PieceData{Path: "M 0 0 L 10 10", Settings: &PieceSettings{Size: 3, Coloer: "#ff0000"}},
+			`<path d="M 0 0 L 10 10" fill="none" stroke="#ff0000" stroke-width="3" stroke-linecap="round" stroke-linejoin="round"/>`,
+		},
+		{
+			"path moved back where it was",
+			&PieceData{Path: "M 0 0 L 10 10", Settings: &PieceSettings{Size: 3, Coloer: "#ff0000"}, Move: DOMMatrixs{A: 1, D: 1}},
+			`<path d="M 0 0 L 10 10" fill="none" stroke="#ff0000" stroke-width="3" stroke-linecap="round" stroke-linejoin="round"/>`,
+		},
+		{
+			"path moved",
+			&PieceData{Path: "M 0 0 L 10 10", Settings: &PieceSettings{Size: 3, Coloer: "#ff0000"}, Move: moved},
+			`<path d="M 0 0 L 10 10" fill="none" stroke="#ff0000" stroke-width="3" stroke-linecap="round" stroke-linejoin="round" transform="matrix(2 0 0 0.5 5 -6.25)"/>`,
+		},
+		{
+			"text moved",
+			&PieceData{Kind: PieceKindText, Text: &TextData{Content: "a\nb", FontSize: 12, X: 1, Y: 2}, Move: moved},
+			`<text x="1" y="2" font-size="12" fill="#000000" dominant-baseline="hanging" xml:space="preserve" transform="matrix(2 0 0 0.5 5 -6.25)"><tspan x="1" dy="0">a</tspan><tspan x="1" dy="1.2em">b</tspan></text>`,
+		},
+	}
+	for _, tc := range tests {
+		canvasData := CanvasData{Name: "test", PiecesManager: &PiecesManager{Pieces: []*PieceData{tc.piece}}}
+		svg := string(RenderSVG(canvasData, SVGOptions{}))
+		if !strings.Contains(svg, tc.expected+"\n") {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, svg)
+		}
+	}
+}
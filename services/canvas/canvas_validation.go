@@ -0,0 +1,159 @@
+package canvas_service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"qolboard-api/config"
+	"strconv"
+	"strings"
+)
+
+const (
+	minStrokeSize = 1
+	maxStrokeSize = 200
+	maxPathLength = 100000 // Bytes of path data in a single piece
+)
+
+var ErrTooManyPieces = errors.New("canvas has too many pieces")
+
+// Checks the colour is one exports can draw: #rgb, #rgba, #rrggbb, #rrggbbaa, rgb(), rgba() or one of a few names
+func ValidateColor(s string) error {
+	c := NormalizeColor(s)
+	if _, ok := namedColors[c]; ok {
+		return nil
+	}
+
+	if hex, ok := strings.CutPrefix(c, "#"); ok {
+		switch len(hex) {
+		case 3, 4, 6, 8:
+			if _, err := strconv.ParseUint(hex, 16, 32); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("color %q is not a valid hex color", s)
+	}
+
+	if args, ok := cutColorFunction(c); ok {
+		parts := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) != 3 && len(parts) != 4 {
+			return fmt.Errorf("color %q needs 3 channels and an optional alpha", s)
+		}
+		for _, part := range parts[:3] {
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil || !(v >= 0 && v <= 255) {
+				return fmt.Errorf("color %q channels must be numbers between 0 and 255", s)
+			}
+		}
+		if len(parts) == 4 {
+			alpha, percent := strings.CutSuffix(parts[3], "%")
+			a, err := strconv.ParseFloat(alpha, 64)
+			if percent {
+				a /= 100
+			}
+			if err != nil || !(a >= 0 && a <= 1) {
+				return fmt.Errorf("color %q alpha must be between 0 and 1, or 0%% and 100%%", s)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("color %q is not supported, use hex, rgb(), rgba() or one of: black, white, red, green, blue, transparent", s)
+}
+
+// The arguments of an rgb() or rgba() colour
+func cutColorFunction(c string) (string, bool) {
+	for _, prefix := range []string{"rgba(", "rgb("} {
+		if args, ok := strings.CutPrefix(c, prefix); ok {
+			return strings.CutSuffix(args, ")")
+		}
+	}
+	return "", false
+}
+
+// Colours are stored trimmed and lower case, so equal colours compare equal
+func NormalizeColor(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func ValidateStrokeSize(size int) error {
+	if size < minStrokeSize || size > maxStrokeSize {
+		return fmt.Errorf("stroke size must be between %d and %d", minStrokeSize, maxStrokeSize)
+	}
+	return nil
+}
+
+func (s *PieceSettings) Validate() error {
+	if s == nil {
+		return errors.New("settings are required")
+	}
+	err := ValidateStrokeSize(s.Size)
+	if err != nil {
+		return err
+	}
+	return ValidateColor(s.Coloer)
+}
+
+// NaN or infinite values would end up in exports and bounds
+func (m DOMMatrixs) Validate() error {
+	values := []float64{
+		m.A, m.B, m.C, m.D, m.E, m.F,
+		m.M11, m.M12, m.M13, m.M14,
+		m.M21, m.M22, m.M23, m.M24,
+		m.M31, m.M32, m.M33, m.M34,
+		m.M41, m.M42, m.M43, m.M44,
+	}
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("move matrix values must be finite numbers")
+		}
+	}
+	return nil
+}
+
+func validatePath(d string) error {
+	if len(d) > maxPathLength {
+		return fmt.Errorf("path data can not be longer than %d bytes", maxPathLength)
+	}
+	_, err := ParsePath(d)
+	if err != nil {
+		return fmt.Errorf("path data is invalid: %w", err)
+	}
+	return nil
+}
+
+// Checks the pieces manager stays within the limits of a single canvas
+func (pm *PiecesManager) ValidateLimits() error {
+	if pm == nil {
+		return nil
+	}
+	if len(pm.Pieces) > config.CanvasMaxPieces() {
+		return fmt.Errorf("%w, it can have at most %d", ErrTooManyPieces, config.CanvasMaxPieces())
+	}
+	return nil
+}
+
+// Tidies up a validated piece, its bounds are worked out again rather than trusting the client's
+func (piece *PieceData) Normalize() {
+	if piece.Settings != nil {
+		piece.Settings.Coloer = NormalizeColor(piece.Settings.Coloer)
+	}
+	piece.SetBounds()
+}
+
+// Tidies up validated canvas data, recomputing the bounds of every piece and of the pieces manager
+func (canvasData *CanvasData) Normalize() {
+	canvasData.BackgroundColor = NormalizeColor(canvasData.BackgroundColor)
+	if canvasData.PieceSettings != nil {
+		canvasData.PieceSettings.Coloer = NormalizeColor(canvasData.PieceSettings.Coloer)
+	}
+	if canvasData.PiecesManager == nil {
+		return
+	}
+	for _, piece := range canvasData.PiecesManager.Pieces {
+		if piece != nil {
+			piece.Normalize()
+		}
+	}
+	canvasData.PiecesManager.SetBounds()
+}
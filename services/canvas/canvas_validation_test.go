@@ -0,0 +1,111 @@
+package canvas_service
+
+import (
+	"errors"
+	"math"
+	"qolboard-api/config"
+	"strings"
+	"testing"
+)
+
+func TestValidateColor(t *testing.T) {
+	valid := []string{"#000", "#0f0a", "#1565C0", "#1565c080", " Black ", "transparent", "rgb(255, 0, 0)", "rgba(0,0,0,0.5)", "rgb(0 0 0 / 50%)"}
+	for _, c := range valid {
+		if err := ValidateColor(c); err != nil {
+			t.Errorf("%q: expected to be valid, got %v", c, err)
+		}
+	}
+
+	invalid := []string{"", "#12", "#12345", "#gggggg", "orange", "rgb(0, 0)", "rgb(256, 0, 0)", "rgba(0, 0, 0, 2)", "rgb(0, 0, 0", "url(#a)", `red" onload="x`}
+	for _, c := range invalid {
+		if err := ValidateColor(c); err == nil {
+			t.Errorf("%q: expected to be invalid", c)
+		}
+	}
+}
+
+func TestPieceSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *PieceSettings
+		valid    bool
+	}{
+		{"missing", nil, false},
+		{"valid", &PieceSettings{Size: 2, Coloer: "#000000"}, true},
+		{"zero size", &PieceSettings{Size: 0, Coloer: "#000000"}, false},
+		{"huge size", &PieceSettings{Size: maxStrokeSize + 1, Coloer: "#000000"}, false},
+		{"bad color", &PieceSettings{Size: 2, Coloer: "nope"}, false},
+	}
+	for _, test := range tests {
+		err := test.settings.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: expected to be valid, got %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected to be invalid", test.name)
+		}
+	}
+}
+
+func TestPieceValidateRejectsBadPathsAndMatrices(t *testing.T) {
+	settings := &PieceSettings{Size: 2, Coloer: "#000000"}
+	tests := []struct {
+		name  string
+		piece PieceData
+	}{
+		{"path syntax", PieceData{Kind: PieceKindPath, Settings: settings, Path: "M 0 0 L x"}},
+		{"path command", PieceData{Kind: PieceKindPath, Settings: settings, Path: "0 0 L 1 1"}},
+		{"long path", PieceData{Kind: PieceKindPath, Settings: settings, Path: "M 0 0" + strings.Repeat(" L 1 1", maxPathLength/6+1)}},
+		{"NaN matrix", PieceData{Kind: PieceKindPath, Settings: settings, Path: "M 0 0 L 1 1", Move: NewDOMMatrix(1, 0, 0, 1, math.NaN(), 0)}},
+		{"infinite matrix", PieceData{Kind: PieceKindPath, Settings: settings, Path: "M 0 0 L 1 1", Move: DOMMatrixs{M44: math.Inf(-1)}}},
+	}
+	for _, test := range tests {
+		if err := test.piece.Validate(); err == nil {
+			t.Errorf("%s: expected to be invalid", test.name)
+		}
+	}
+}
+
+func TestPiecesManagerValidateLimits(t *testing.T) {
+	pm := &PiecesManager{Pieces: make([]*PieceData, config.CanvasMaxPieces())}
+	if err := pm.ValidateLimits(); err != nil {
+		t.Fatalf("expected a full canvas to be valid, got %v", err)
+	}
+
+	pm.Pieces = append(pm.Pieces, &PieceData{})
+	if err := pm.ValidateLimits(); !errors.Is(err, ErrTooManyPieces) {
+		t.Fatalf("expected ErrTooManyPieces, got %v", err)
+	}
+}
+
+func TestCanvasDataNormalizeRecomputesBounds(t *testing.T) {
+	lie := -1000.0
+	piece := &PieceData{
+		Kind:       PieceKindPath,
+		Settings:   &PieceSettings{Size: 2, Coloer: " #FF0000 "},
+		Path:       "M 0 0 L 10 20",
+		Move:       NewDOMMatrix(1, 0, 0, 1, 5, 5),
+		LeftMost:   &lie,
+		RightMost:  &lie,
+		TopMost:    &lie,
+		BottomMost: &lie,
+	}
+	canvasData := CanvasData{
+		BackgroundColor: "#FFFFFF",
+		PiecesManager:   &PiecesManager{Pieces: []*PieceData{piece}, LeftMost: &lie, RightMost: &lie, TopMost: &lie, BottomMost: &lie},
+	}
+
+	canvasData.Normalize()
+
+	if canvasData.BackgroundColor != "#ffffff" || piece.Settings.Coloer != "#ff0000" {
+		t.Errorf("expected colors to be normalized, got %q and %q", canvasData.BackgroundColor, piece.Settings.Coloer)
+	}
+	bounds, _ := piece.Bounds()
+	if bounds != (Bounds{Left: 5, Right: 15, Top: 5, Bottom: 25}) {
+		t.Errorf("expected the piece bounds to be recomputed, got %+v", bounds)
+	}
+	bounds, _ = canvasData.PiecesManager.Bounds()
+	if bounds != (Bounds{Left: 5, Right: 15, Top: 5, Bottom: 25}) {
+		t.Errorf("expected the pieces manager bounds to be recomputed, got %+v", bounds)
+	}
+}
@@ -3,6 +3,7 @@ package error_service
 import (
 	"errors"
 	"fmt"
+	canvas_service "qolboard-api/services/canvas"
 	"qolboard-api/services/logging"
 	"reflect"
 	"strings"
@@ -38,6 +39,42 @@ func SetUpValidator() {
 			}
 			return name
 		})
+
+		// Canvas payloads have rules which don't fit in struct tags, each piece kind has its own too
+		v.RegisterStructValidation(func(sl validator.StructLevel) {
+			canvasData := sl.Current().Interface().(canvas_service.CanvasData)
+			err := canvas_service.ValidateColor(canvasData.BackgroundColor)
+			if err != nil {
+				sl.ReportError(canvasData.BackgroundColor, "backgroundColor", "BackgroundColor", "canvas", err.Error())
+			}
+		}, canvas_service.CanvasData{})
+		v.RegisterStructValidation(func(sl validator.StructLevel) {
+			settings := sl.Current().Interface().(canvas_service.PieceSettings)
+			err := canvas_service.ValidateStrokeSize(settings.Size)
+			if err != nil {
+				sl.ReportError(settings.Size, "size", "Size", "canvas", err.Error())
+			}
+			err = canvas_service.ValidateColor(settings.Coloer)
+			if err != nil {
+				sl.ReportError(settings.Coloer, "color", "Coloer", "canvas", err.Error())
+			}
+		}, canvas_service.PieceSettings{})
+		v.RegisterStructValidation(func(sl validator.StructLevel) {
+			pm := sl.Current().Interface().(canvas_service.PiecesManager)
+			err := pm.ValidateLimits()
+			if err != nil {
+				sl.ReportError(pm.Pieces, "pieces", "Pieces", "canvas", err.Error())
+			}
+			for i, piece := range pm.Pieces {
+				if piece == nil {
+					continue
+				}
+				err := piece.Validate()
+				if err != nil {
+					sl.ReportError(piece, fmt.Sprintf("pieces[%d]", i), "Pieces", "piece", err.Error())
+				}
+			}
+		}, canvas_service.PiecesManager{})
 	}
 }
 
@@ -139,6 +176,10 @@ func newValidationErrors(err gin.Error) (int, []*Error) {
 			if v.Tag() == "gte" {
 				message = fmt.Sprintf("%s must be greater than or equal to %s", fieldEnglish, v.Param())
 			}
+			if v.Tag() == "piece" || v.Tag() == "canvas" {
+				message = fmt.Sprintf("%s is invalid: %s", fieldEnglish, v.Param())
+				value = ""
+			}
 
 			var newError *Error = &Error{
 				Message: message,
@@ -0,0 +1,296 @@
+// Converts between Excalidraw scenes and canvas data.
+//
+// Conversions are lossy in both directions:
+//   - Only freedraw, line, arrow, rectangle and ellipse elements are imported, others (text, diamond, image, frame...) are skipped and counted.
+//   - Fills, fill styles, opacity, roughness, stroke styles (dashed/dotted), rounded corners, pressure and bindings are dropped on import.
+//   - Curved lines and arrows are imported as straight segments between their points, arrowheads become two short strokes.
+//   - Stroke widths are rounded to whole numbers.
+//   - Every piece is exported as a freedraw element, so rectangles, ellipses and arrows come back as freehand strokes.
+//     Curves are flattened into points and piece transforms are baked into them, pieces with several subpaths become grouped elements.
+//   - Text and image pieces are not exported.
+package excalidraw_service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	canvas_service "qolboard-api/services/canvas"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoSupportedElements = errors.New("scene does not contain any supported elements")
+
+const (
+	ElementFreedraw  = "freedraw"
+	ElementLine      = "line"
+	ElementArrow     = "arrow"
+	ElementRectangle = "rectangle"
+	ElementEllipse   = "ellipse"
+)
+
+type Scene struct {
+	Type     string         `json:"type"`
+	Version  int            `json:"version"`
+	Source   string         `json:"source"`
+	Elements []Element      `json:"elements"`
+	AppState AppState       `json:"appState"`
+	Files    map[string]any `json:"files"`
+}
+
+type AppState struct {
+	ViewBackgroundColor string `json:"viewBackgroundColor,omitempty"`
+	Name                string `json:"name,omitempty"`
+}
+
+// The subset of an Excalidraw element we read and write, Excalidraw fills in defaults for anything missing when loading
+type Element struct {
+	ID               string       `json:"id"`
+	Type             string       `json:"type"`
+	X                float64      `json:"x"`
+	Y                float64      `json:"y"`
+	Width            float64      `json:"width"`
+	Height           float64      `json:"height"`
+	Angle            float64      `json:"angle"`
+	StrokeColor      string       `json:"strokeColor"`
+	BackgroundColor  string       `json:"backgroundColor"`
+	FillStyle        string       `json:"fillStyle"`
+	StrokeWidth      float64      `json:"strokeWidth"`
+	StrokeStyle      string       `json:"strokeStyle"`
+	Roughness        int          `json:"roughness"`
+	Opacity          int          `json:"opacity"`
+	GroupIds         []string     `json:"groupIds"`
+	Seed             int          `json:"seed"`
+	Version          int          `json:"version"`
+	VersionNonce     int          `json:"versionNonce"`
+	IsDeleted        bool         `json:"isDeleted"`
+	Updated          int64        `json:"updated"`
+	Points           [][2]float64 `json:"points,omitempty"`
+	Pressures        []float64    `json:"pressures,omitempty"`
+	SimulatePressure bool         `json:"simulatePressure,omitempty"`
+	StartArrowhead   *string      `json:"startArrowhead,omitempty"`
+	EndArrowhead     *string      `json:"endArrowhead,omitempty"`
+}
+
+// Converts a scene to canvas data, returns how many elements of each unsupported type were skipped
+func Import(scene Scene) (canvas_service.CanvasData, map[string]int, error) {
+	canvasData := canvas_service.CanvasData{
+		Name:            scene.AppState.Name,
+		BackgroundColor: scene.AppState.ViewBackgroundColor,
+		PieceSettings:   &canvas_service.PieceSettings{Size: 2, Coloer: "#000000"},
+		PiecesManager:   &canvas_service.PiecesManager{Pieces: make([]*canvas_service.PieceData, 0)},
+	}
+	if canvasData.BackgroundColor == "" {
+		canvasData.BackgroundColor = "#ffffff"
+	}
+	skipped := make(map[string]int)
+
+	for _, el := range scene.Elements {
+		if el.IsDeleted {
+			continue
+		}
+
+		path := elementPath(el)
+		if path == "" {
+			skipped[el.Type]++
+			continue
+		}
+
+		id, err := canvas_service.NewPieceID()
+		if err != nil {
+			return canvasData, skipped, err
+		}
+
+		colour := el.StrokeColor
+		if colour == "" || colour == "transparent" {
+			colour = "#000000"
+		}
+
+		piece := &canvas_service.PieceData{
+			Kind:     canvas_service.PieceKindPath,
+			ID:       id,
+			Settings: &canvas_service.PieceSettings{Size: max(1, int(math.Round(el.StrokeWidth))), Coloer: colour},
+			Path:     path,
+			Move:     elementMatrix(el),
+		}
+		if !piece.SetBounds() {
+			skipped[el.Type]++
+			continue
+		}
+		canvasData.PiecesManager.Pieces = append(canvasData.PiecesManager.Pieces, piece)
+	}
+
+	if len(canvasData.PiecesManager.Pieces) == 0 {
+		return canvasData, skipped, ErrNoSupportedElements
+	}
+	canvasData.PiecesManager.SetBounds()
+
+	return canvasData, skipped, nil
+}
+
+// Path data for the element in its own coordinates, empty when the element type is not supported
+func elementPath(el Element) string {
+	switch el.Type {
+	case ElementFreedraw, ElementLine, ElementArrow:
+		if len(el.Points) == 0 {
+			return ""
+		}
+		var d strings.Builder
+		for i, pt := range el.Points {
+			command := "L"
+			if i == 0 {
+				command = "M"
+			}
+			fmt.Fprintf(&d, "%s %s %s ", command, formatFloat(pt[0]), formatFloat(pt[1]))
+		}
+		if el.Type == ElementArrow && len(el.Points) > 1 {
+			n := len(el.Points)
+			if el.EndArrowhead != nil {
+				d.WriteString(arrowhead(el.Points[n-2], el.Points[n-1], el.StrokeWidth))
+			}
+			if el.StartArrowhead != nil {
+				d.WriteString(arrowhead(el.Points[1], el.Points[0], el.StrokeWidth))
+			}
+		}
+		return strings.TrimSpace(d.String())
+
+	case ElementRectangle:
+		return fmt.Sprintf("M 0 0 H %s V %s H 0 Z", formatFloat(el.Width), formatFloat(el.Height))
+
+	case ElementEllipse:
+		rx, ry := el.Width/2, el.Height/2
+		return fmt.Sprintf("M 0 %s A %s %s 0 1 0 %s %s A %s %s 0 1 0 0 %s Z",
+			formatFloat(ry),
+			formatFloat(rx), formatFloat(ry), formatFloat(el.Width), formatFloat(ry),
+			formatFloat(rx), formatFloat(ry), formatFloat(ry))
+	}
+
+	return ""
+}
+
+// Two strokes back from the tip, at 30 degrees either side of the direction from -> tip
+func arrowhead(from [2]float64, tip [2]float64, strokeWidth float64) string {
+	direction := math.Atan2(tip[1]-from[1], tip[0]-from[0])
+	length := math.Max(10, strokeWidth*5)
+	var d strings.Builder
+	for _, side := range []float64{-1, 1} {
+		angle := direction + math.Pi - side*math.Pi/6
+		fmt.Fprintf(&d, "M %s %s L %s %s ",
+			formatFloat(tip[0]), formatFloat(tip[1]),
+			formatFloat(tip[0]+length*math.Cos(angle)), formatFloat(tip[1]+length*math.Sin(angle)))
+	}
+	return d.String()
+}
+
+// Places the element's own coordinates on the canvas, Excalidraw rotates elements around the centre of their box
+func elementMatrix(el Element) canvas_service.DOMMatrixs {
+	cos, sin := math.Cos(el.Angle), math.Sin(el.Angle)
+	cx, cy := el.Width/2, el.Height/2
+	return canvas_service.NewDOMMatrix(
+		cos, sin, -sin, cos,
+		el.X+cx-cos*cx+sin*cy,
+		el.Y+cy-sin*cx-cos*cy,
+	)
+}
+
+// Converts canvas data to a scene, every piece becomes one freedraw element per subpath
+func Export(canvasData canvas_service.CanvasData) Scene {
+	scene := Scene{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   "qolboard",
+		Elements: make([]Element, 0),
+		AppState: AppState{
+			ViewBackgroundColor: canvasData.BackgroundColor,
+			Name:                canvasData.Name,
+		},
+		Files: map[string]any{},
+	}
+
+	if canvasData.PiecesManager == nil {
+		return scene
+	}
+
+	now := time.Now().UnixMilli()
+	for _, piece := range canvasData.PiecesManager.Pieces {
+		if piece == nil {
+			continue
+		}
+		subpaths, err := canvas_service.ParsePath(piece.Outline())
+		if err != nil || len(subpaths) == 0 {
+			continue
+		}
+
+		colour := "#000000"
+		strokeWidth := 1.0
+		if piece.Settings != nil {
+			if piece.Settings.Coloer != "" {
+				colour = piece.Settings.Coloer
+			}
+			strokeWidth = float64(piece.Settings.Size)
+		}
+
+		groupIds := []string{}
+		if len(subpaths) > 1 {
+			groupIds = []string{piece.ID}
+		}
+
+		for i, subpath := range subpaths {
+			id := piece.ID
+			if i > 0 {
+				id = fmt.Sprintf("%s-%d", piece.ID, i)
+			}
+			scene.Elements = append(scene.Elements, freedrawElement(id, subpath, piece.Move, colour, strokeWidth, groupIds, now))
+		}
+	}
+
+	return scene
+}
+
+func freedrawElement(id string, subpath []canvas_service.Point, move canvas_service.DOMMatrixs, colour string, strokeWidth float64, groupIds []string, updated int64) Element {
+	points := make([]canvas_service.Point, len(subpath))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, pt := range subpath {
+		points[i] = move.Apply(pt)
+		minX, maxX = math.Min(minX, points[i].X), math.Max(maxX, points[i].X)
+		minY, maxY = math.Min(minY, points[i].Y), math.Max(maxY, points[i].Y)
+	}
+
+	// Freedraw points are relative to the element's position, which is where the stroke starts
+	origin := points[0]
+	relative := make([][2]float64, len(points))
+	for i, pt := range points {
+		relative[i] = [2]float64{pt.X - origin.X, pt.Y - origin.Y}
+	}
+
+	return Element{
+		ID:               id,
+		Type:             ElementFreedraw,
+		X:                origin.X,
+		Y:                origin.Y,
+		Width:            maxX - minX,
+		Height:           maxY - minY,
+		StrokeColor:      colour,
+		BackgroundColor:  "transparent",
+		FillStyle:        "solid",
+		StrokeWidth:      strokeWidth,
+		StrokeStyle:      "solid",
+		Roughness:        0,
+		Opacity:          100,
+		GroupIds:         groupIds,
+		Seed:             rand.IntN(math.MaxInt32),
+		Version:          1,
+		VersionNonce:     rand.IntN(math.MaxInt32),
+		Updated:          updated,
+		Points:           relative,
+		Pressures:        []float64{},
+		SimulatePressure: true,
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,98 @@
+package excalidraw_service
+
+import (
+	"math"
+	canvas_service "qolboard-api/services/canvas"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	arrowhead := "arrow"
+	scene := Scene{
+		AppState: AppState{ViewBackgroundColor: "#fafafa", Name: "Flow"},
+		Elements: []Element{
+			{Type: ElementRectangle, X: 10, Y: 20, Width: 100, Height: 50, StrokeColor: "#1e1e1e", StrokeWidth: 2},
+			{Type: ElementRectangle, X: 0, Y: 0, Width: 10, Height: 10, Angle: math.Pi / 2, StrokeWidth: 1},
+			{Type: ElementArrow, X: 0, Y: 0, Points: [][2]float64{{0, 0}, {100, 0}}, EndArrowhead: &arrowhead, StrokeWidth: 1},
+			{Type: ElementFreedraw, IsDeleted: true, Points: [][2]float64{{0, 0}, {1, 1}}},
+			{Type: "text"},
+			{Type: "text"},
+		},
+	}
+
+	canvasData, skipped, err := Import(scene)
+	if err != nil {
+		t.Fatalf("failed to import scene: %v", err)
+	}
+	if canvasData.Name != "Flow" || canvasData.BackgroundColor != "#fafafa" {
+		t.Errorf("expected app state to carry over, got %q %q", canvasData.Name, canvasData.BackgroundColor)
+	}
+	if skipped["text"] != 2 {
+		t.Errorf("expected 2 skipped text elements, got %v", skipped)
+	}
+
+	pieces := canvasData.PiecesManager.Pieces
+	if len(pieces) != 3 {
+		t.Fatalf("expected 3 pieces, deleted elements are left out, got %d", len(pieces))
+	}
+
+	rect := pieces[0]
+	if *rect.LeftMost != 10 || *rect.RightMost != 110 || *rect.TopMost != 20 || *rect.BottomMost != 70 {
+		t.Errorf("unexpected rectangle bounds %v %v %v %v", *rect.LeftMost, *rect.RightMost, *rect.TopMost, *rect.BottomMost)
+	}
+	if rect.Settings.Coloer != "#1e1e1e" || rect.Settings.Size != 2 {
+		t.Errorf("unexpected rectangle settings %+v", *rect.Settings)
+	}
+
+	// Rotation is around the centre of the element's box, a square turned a quarter stays in place
+	rotated := pieces[1]
+	if math.Abs(*rotated.LeftMost) > 1e-9 || math.Abs(*rotated.RightMost-10) > 1e-9 {
+		t.Errorf("expected the rotated square to stay in place, got %v %v", *rotated.LeftMost, *rotated.RightMost)
+	}
+
+	// The arrowhead strokes reach back from the tip
+	arrow := pieces[2]
+	if *arrow.RightMost != 100 || *arrow.TopMost >= 0 || *arrow.BottomMost <= 0 {
+		t.Errorf("expected arrowhead strokes around the tip, got %v %v %v", *arrow.RightMost, *arrow.TopMost, *arrow.BottomMost)
+	}
+
+	if _, _, err := Import(Scene{Elements: []Element{{Type: "text"}}}); err != ErrNoSupportedElements {
+		t.Errorf("expected a scene without supported elements to be rejected, got %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	canvasData := canvas_service.CanvasData{
+		Name:            "Board",
+		BackgroundColor: "#ffffff",
+		PiecesManager: &canvas_service.PiecesManager{
+			Pieces: []*canvas_service.PieceData{
+				{
+					ID:       "piece",
+					Path:     "M 0 0 L 10 0 M 0 5 L 10 5",
+					Settings: &canvas_service.PieceSettings{Size: 3, Coloer: "#ff0000"},
+					Move:     canvas_service.NewDOMMatrix(1, 0, 0, 1, 100, 200),
+				},
+			},
+		},
+	}
+
+	scene := Export(canvasData)
+	if scene.Type != "excalidraw" || scene.AppState.Name != "Board" {
+		t.Errorf("unexpected scene header %q %+v", scene.Type, scene.AppState)
+	}
+	if len(scene.Elements) != 2 {
+		t.Fatalf("expected one element per subpath, got %d", len(scene.Elements))
+	}
+
+	second := scene.Elements[1]
+	if second.Type != ElementFreedraw || second.X != 100 || second.Y != 205 || second.Width != 10 {
+		t.Errorf("expected the transform to be baked into the element, got %+v", second)
+	}
+	if len(second.GroupIds) != 1 || second.GroupIds[0] != "piece" || second.ID == scene.Elements[0].ID {
+		t.Errorf("expected subpaths to be grouped under the piece, got %v %q", second.GroupIds, second.ID)
+	}
+	if second.StrokeColor != "#ff0000" || second.StrokeWidth != 3 {
+		t.Errorf("unexpected stroke %q %v", second.StrokeColor, second.StrokeWidth)
+	}
+}
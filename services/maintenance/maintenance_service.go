@@ -0,0 +1,109 @@
+package maintenance_service
+
+import (
+	"context"
+	"encoding/json"
+	database_config "qolboard-api/config/database"
+	canvas_model "qolboard-api/models/canvas"
+	"qolboard-api/services/database"
+	websocket_service "qolboard-api/services/websocket"
+	"time"
+)
+
+const recompressBatchSize = 50
+
+// Rooms save every RoomSaveInterval, so a canvas saved more recently than this likely has one open
+const liveRoomWindow = 2 * websocket_service.RoomSaveInterval
+
+type RecompressReport struct {
+	Canvases     int   // Canvases looked at
+	Skipped      int   // Canvases left alone as they looked open in a live room
+	Recompressed int   // Canvases which got smaller
+	Pieces       int   // Pieces simplified in those canvases
+	BytesBefore  int64 // Canvas data sizes as JSON, of every canvas looked at
+	BytesAfter   int64
+}
+
+func (r RecompressReport) BytesSaved() int64 {
+	return r.BytesBefore - r.BytesAfter
+}
+
+// Simplifies the freehand paths of every canvas with the tolerance, only canvases which get smaller are written back.
+// Canvases open in a live room are skipped, the room would save its unsimplified copy over them.
+// With dryRun nothing is written and the report shows what would be saved. Stops early when ctx is done, reporting what was done so far
+func RecompressCanvases(ctx context.Context, tolerance float64, dryRun bool) (RecompressReport, error) {
+	report := RecompressReport{}
+	afterId := "00000000-0000-0000-0000-000000000000"
+	for ctx.Err() == nil {
+		found, lastId, err := recompressBatch(afterId, tolerance, dryRun, &report)
+		if err != nil {
+			return report, err
+		}
+		if found < recompressBatchSize {
+			return report, nil
+		}
+		afterId = lastId
+	}
+	return report, ctx.Err()
+}
+
+// Each batch is its own transaction, its canvases are locked so saves meanwhile wait for it. A room keeps its own copy of the
+// canvas and writes it back on every save, so canvases recently saved by one are skipped rather than recompressed for nothing
+func recompressBatch(afterId string, tolerance float64, dryRun bool, report *RecompressReport) (int, string, error) {
+	tx, err := database_config.DB(nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer database.StandardDeferRollback(tx)
+
+	canvases, err := canvas_model.GetBatchForUpdate(tx, afterId, recompressBatchSize)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(canvases) == 0 {
+		return 0, afterId, nil
+	}
+
+	for _, canvas := range canvases {
+		if time.Since(canvas.UpdatedAt) < liveRoomWindow {
+			report.Skipped++
+			continue
+		}
+
+		before, err := json.Marshal(canvas.CanvasData)
+		if err != nil {
+			return 0, "", err
+		}
+		report.Canvases++
+		report.BytesBefore += int64(len(before))
+
+		pieces := canvas.CanvasData.SimplifyPaths(tolerance)
+		after, err := json.Marshal(canvas.CanvasData)
+		if err != nil {
+			return 0, "", err
+		}
+		if pieces == 0 || len(after) >= len(before) {
+			report.BytesAfter += int64(len(before))
+			continue
+		}
+		report.Recompressed++
+		report.Pieces += pieces
+		report.BytesAfter += int64(len(after))
+
+		if !dryRun {
+			err = canvas_model.SetCanvasData(tx, canvas.ID, canvas.CanvasData)
+			if err != nil {
+				return 0, "", err
+			}
+		}
+	}
+
+	if !dryRun {
+		err = tx.Commit()
+		if err != nil {
+			return 0, "", err
+		}
+	}
+
+	return len(canvases), canvases[len(canvases)-1].ID, nil
+}
@@ -2,6 +2,7 @@
 package relations_service
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"qolboard-api/services/logging"
@@ -172,6 +173,9 @@ func makeSingleLoader[TModel IHasRelations, TRelated IHasRelations](
 	return func(tx *sqlx.Tx, model *IHasRelations) ([]IHasRelations, error) {
 		related := new(TRelated)
 		err := tx.Get(related, query, getModelForeignKey((*model).(TModel)))
+		if errors.Is(err, sql.ErrNoRows) {
+			return []IHasRelations{}, nil // Optional relation which isn't there, e.g. a canvas without a thumbnail yet
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,56 @@
+package storage_service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Put(ctx, "assets/canvas/abc", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.Get(ctx, "assets/canvas/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(contents) != "hello" {
+		t.Fatalf("expected to read back what was stored, got %q, %v", contents, err)
+	}
+
+	err = s.Delete(ctx, "assets/canvas/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "assets/canvas/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after deleting, got %v", err)
+	}
+	if err := s.Delete(ctx, "assets/canvas/abc"); err != nil {
+		t.Errorf("expected deleting a missing key to succeed, got %v", err)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"", "/abs", "a/../b", "..", "a//b", "a/b/", "a\\b", "a b"} {
+		if ValidateKey(key) == nil {
+			t.Errorf("expected %q to be rejected", key)
+		}
+	}
+	for _, key := range []string{"a", "assets/1234-abcd/Xy_9", "a/b.png"} {
+		if err := ValidateKey(key); err != nil {
+			t.Errorf("expected %q to be accepted, got %v", key, err)
+		}
+	}
+}
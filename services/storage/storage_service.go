@@ -0,0 +1,33 @@
+package storage_service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var ErrNotFound = errors.New("stored object not found")
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Where uploaded files live, keys are generated by the API and are slash separated like "assets/<canvas id>/<code>"
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Callers must close the returned reader, ErrNotFound when nothing is stored under the key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Deleting a key which doesn't exist is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Keys are kept to a safe subset so every backend can store them as they are, and none can escape a local root directory
+var validKeySegment = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func ValidateKey(key string) error {
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." || !validKeySegment.MatchString(segment) {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
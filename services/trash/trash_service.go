@@ -0,0 +1,116 @@
+package trash_service
+
+import (
+	"context"
+	database_config "qolboard-api/config/database"
+	asset_model "qolboard-api/models/asset"
+	canvas_model "qolboard-api/models/canvas"
+	"qolboard-api/services/database"
+	"qolboard-api/services/logging"
+	storage_service "qolboard-api/services/storage"
+	"time"
+)
+
+const orphanedAssetsBatchSize = 100
+
+// Permanently deletes canvases which have been in the trash for longer than retention, and the stored files of their assets,
+// every interval until ctx is done
+func PeriodicPurge(ctx context.Context, interval time.Duration, retention time.Duration, storage storage_service.Storage) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := Purge(time.Now().Add(-retention))
+			if err != nil {
+				logging.LogError("trash_service", "Error purging trash", err)
+				continue
+			}
+			if purged > 0 {
+				logging.LogInfo("trash_service", "Purged canvases from trash", map[string]any{
+					"canvases": purged,
+				})
+			}
+
+			// Also picks up assets of canvases purged straight from the trash by their owner
+			purged, err = PurgeOrphanedAssets(ctx, storage)
+			if err != nil {
+				logging.LogError("trash_service", "Error purging orphaned assets", err)
+				continue
+			}
+			if purged > 0 {
+				logging.LogInfo("trash_service", "Purged orphaned assets", map[string]any{
+					"assets": purged,
+				})
+			}
+		case <-ctx.Done():
+			logging.LogInfo("trash_service", "ctx done, finishing", nil)
+			return
+		}
+	}
+}
+
+// Permanently deletes every canvas deleted before the cutoff
+func Purge(cutoff time.Time) (int64, error) {
+	tx, err := database_config.DB(nil)
+	if err != nil {
+		return 0, err
+	}
+	defer database.StandardDeferRollback(tx)
+
+	purged, err := canvas_model.PurgeDeletedBefore(tx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, tx.Commit()
+}
+
+// Deletes the stored files of assets whose canvas was purged, then the assets themselves
+func PurgeOrphanedAssets(ctx context.Context, storage storage_service.Storage) (int64, error) {
+	var purged int64
+	for ctx.Err() == nil {
+		found, deleted, err := purgeOrphanedAssetsBatch(ctx, storage)
+		purged += deleted
+		if err != nil {
+			return purged, err
+		}
+		// Stop when there are none left, or only ones whose files can't be deleted right now
+		if found < orphanedAssetsBatchSize || deleted == 0 {
+			break
+		}
+	}
+	return purged, nil
+}
+
+func purgeOrphanedAssetsBatch(ctx context.Context, storage storage_service.Storage) (int, int64, error) {
+	tx, err := database_config.DB(nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer database.StandardDeferRollback(tx)
+
+	assets, err := asset_model.GetOrphaned(tx, orphanedAssetsBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		err = storage.Delete(ctx, asset.StorageKey)
+		if err != nil {
+			// The row is kept so the next run tries again
+			logging.LogError("trash_service", "Error deleting stored asset file", err)
+			continue
+		}
+		ids = append(ids, asset.ID)
+	}
+
+	err = asset_model.Purge(tx, ids)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return len(assets), int64(len(ids)), tx.Commit()
+}
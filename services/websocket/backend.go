@@ -0,0 +1,88 @@
+package websocket_service
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	envelopeKindMessage     = "message"      // A room message, mutating events are applied by receiving rooms
+	envelopeKindCanvasData  = "canvas-data"  // The canvas data was replaced wholesale
+	envelopeKindRole        = "role"         // A collaborator's role changed
+	envelopeKindSyncRequest = "sync-request" // A new room asks rooms elsewhere for their live canvas data and presence
+	envelopeKindPresence    = "presence"     // Reply to a sync request listing who is connected to the replying room
+)
+
+var ErrBackendClosed = errors.New("websocket backend closed")
+
+// Carries room traffic between RoomsManagers, which may live in different API instances
+type Backend interface {
+	// Publish sends an envelope to every subscriber, including the publisher's own subscription
+	Publish(env Envelope) error
+	// Subscribe returns a channel which receives every published envelope, it is closed once the backend is closed
+	Subscribe() <-chan Envelope
+	Close() error
+}
+
+type Envelope struct {
+	Origin   string         `json:"origin"`           // Id of the publishing RoomsManager
+	Target   string         `json:"target,omitempty"` // Id of the only RoomsManager that should handle this, empty for everyone
+	Kind     string         `json:"kind"`
+	CanvasId string         `json:"canvas_id"`
+	Event    string         `json:"event,omitempty"`
+	Email    string         `json:"email,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+}
+
+// Fans envelopes out to subscribers within a single process, suitable when only one API instance is running
+type MemoryBackend struct {
+	mu          sync.Mutex
+	subscribers []chan Envelope
+	closed      bool
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		subscribers: make([]chan Envelope, 0),
+	}
+}
+
+func (b *MemoryBackend) Publish(env Envelope) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBackendClosed
+	}
+	for _, ch := range b.subscribers {
+		ch <- env
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Subscribe() <-chan Envelope {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Envelope, 256)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers = append(b.subscribers, ch)
+	return ch
+}
+
+func (b *MemoryBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, ch := range b.subscribers {
+		close(ch)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package websocket_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	service "qolboard-api/services"
+	"qolboard-api/services/logging"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// Postgres rejects NOTIFY payloads of 8000 bytes or more, leave room for the chunk header
+	notifyChunkSize = 7000
+	// Chunks of a message that never fully arrived (e.g. the listener reconnected halfway) are dropped after this
+	notifyChunkTimeout = 1 * time.Minute
+	// How long to wait before trying to re-establish a lost LISTEN connection
+	listenRetryInterval = 1 * time.Second
+)
+
+// Fans envelopes out between API instances using Postgres LISTEN/NOTIFY.
+// Envelopes larger than a single NOTIFY payload are split into chunks, all sent within one transaction.
+// Anything published while the listener is reconnecting is missed by this instance.
+type PostgresBackend struct {
+	dsn     string
+	channel string
+	db      *sqlx.DB
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu          sync.Mutex
+	subscribers []chan Envelope
+	partials    map[string]*partialEnvelope
+	closed      bool
+}
+
+type partialEnvelope struct {
+	chunks   []string
+	received int
+	started  time.Time
+}
+
+// Connects to Postgres and starts listening on channel, returns once the first LISTEN is active
+func NewPostgresBackend(ctx context.Context, dsn string, channel string) (*PostgresBackend, error) {
+	db, err := sqlx.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open websocket backend publish connection: %w", err)
+	}
+
+	conn, err := listen(ctx, dsn, channel)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	b := &PostgresBackend{
+		dsn:         dsn,
+		channel:     channel,
+		db:          db,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		subscribers: make([]chan Envelope, 0),
+		partials:    make(map[string]*partialEnvelope),
+	}
+
+	go b.run(ctx, conn)
+
+	return b, nil
+}
+
+func listen(ctx context.Context, dsn string, channel string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open websocket backend listen connection: %w", err)
+	}
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	if err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("failed to listen on websocket backend channel: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (b *PostgresBackend) run(ctx context.Context, conn *pgx.Conn) {
+	defer close(b.done)
+	defer b.closeSubscribers()
+
+	for {
+		for conn != nil {
+			notification, err := conn.WaitForNotification(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logging.LogError("WebSocket", "Lost websocket backend listen connection", err)
+				}
+				conn.Close(context.Background())
+				conn = nil
+				break
+			}
+			b.receive(notification.Payload)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryInterval):
+		}
+
+		var err error
+		conn, err = listen(ctx, b.dsn, b.channel)
+		if err != nil {
+			logging.LogError("WebSocket", "Failed to re-establish websocket backend listen connection", err)
+		}
+	}
+}
+
+// Reassembles a chunk and forwards the envelope to subscribers once all of its chunks have arrived
+func (b *PostgresBackend) receive(payload string) {
+	// Chunk format: "<message id> <index> <total> <part>"
+	parts := strings.SplitN(payload, " ", 4)
+	if len(parts) != 4 {
+		logging.LogError("WebSocket", "Malformed websocket backend notification", payload)
+		return
+	}
+	index, errIndex := strconv.Atoi(parts[1])
+	total, errTotal := strconv.Atoi(parts[2])
+	if errIndex != nil || errTotal != nil || total < 1 || index < 0 || index >= total {
+		logging.LogError("WebSocket", "Malformed websocket backend notification header", parts[:3])
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for id, partial := range b.partials {
+		if now.Sub(partial.started) > notifyChunkTimeout {
+			delete(b.partials, id)
+		}
+	}
+
+	partial, exists := b.partials[parts[0]]
+	if !exists {
+		partial = &partialEnvelope{
+			chunks:  make([]string, total),
+			started: now,
+		}
+		b.partials[parts[0]] = partial
+	}
+	if len(partial.chunks) != total || partial.chunks[index] != "" {
+		return // Duplicate or inconsistent chunk
+	}
+	partial.chunks[index] = parts[3]
+	partial.received++
+	if partial.received < total {
+		return
+	}
+	delete(b.partials, parts[0])
+
+	var env Envelope
+	err := json.Unmarshal([]byte(strings.Join(partial.chunks, "")), &env)
+	if err != nil {
+		logging.LogError("WebSocket", "Failed to unmarshal websocket backend envelope", err)
+		return
+	}
+
+	for _, ch := range b.subscribers {
+		ch <- env
+	}
+}
+
+func (b *PostgresBackend) Publish(env Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal websocket backend envelope: %w", err)
+	}
+
+	id, err := service.GenerateCode(16)
+	if err != nil {
+		return err
+	}
+
+	chunks := splitPayload(payload, notifyChunkSize)
+
+	tx, err := b.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin websocket backend publish transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, chunk := range chunks {
+		_, err = tx.Exec("SELECT pg_notify($1, $2)", b.channel, fmt.Sprintf("%s %d %d %s", id, i, len(chunks), chunk))
+		if err != nil {
+			return fmt.Errorf("failed to notify websocket backend channel: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Splits payload into chunks of at most size bytes, never splitting a multi-byte character
+func splitPayload(payload []byte, size int) []string {
+	chunks := make([]string, 0, len(payload)/size+1)
+	for len(payload) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(payload[cut]) {
+			cut--
+		}
+		chunks = append(chunks, string(payload[:cut]))
+		payload = payload[cut:]
+	}
+	return append(chunks, string(payload))
+}
+
+func (b *PostgresBackend) Subscribe() <-chan Envelope {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Envelope, 256)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers = append(b.subscribers, ch)
+	return ch
+}
+
+func (b *PostgresBackend) closeSubscribers() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, ch := range b.subscribers {
+		close(ch)
+	}
+	b.subscribers = nil
+}
+
+func (b *PostgresBackend) Close() error {
+	b.cancel()
+	<-b.done
+	return b.db.Close()
+}
@@ -0,0 +1,101 @@
+package websocket_service
+
+import (
+	"qolboard-api/config"
+
+	"golang.org/x/time/rate"
+)
+
+// Events which are only relayed to other clients, never applied to or saved with the canvas
+var ephemeralEvents = []string{
+	"cursor",
+	"viewport",
+}
+
+type Presence struct {
+	ConnectionId string `json:"connection_id"`
+	UserId       string `json:"user_id"`
+	Email        string `json:"email"`
+}
+
+type dataChPresence struct {
+	canvasId string
+	chResult chan []Presence
+}
+
+func (p Presence) toMap() map[string]any {
+	return map[string]any{
+		"connection_id": p.ConnectionId,
+		"user_id":       p.UserId,
+		"email":         p.Email,
+	}
+}
+
+func presenceFromMap(data map[string]any) Presence {
+	p := Presence{}
+	p.ConnectionId, _ = data["connection_id"].(string)
+	p.UserId, _ = data["user_id"].(string)
+	p.Email, _ = data["email"].(string)
+	return p
+}
+
+func presenceListToMap(list []Presence) map[string]any {
+	users := make([]any, 0, len(list))
+	for _, p := range list {
+		users = append(users, p.toMap())
+	}
+	return map[string]any{
+		"users": users,
+	}
+}
+
+func presenceListFromMap(data map[string]any) []Presence {
+	users, _ := data["users"].([]any)
+	list := make([]Presence, 0, len(users))
+	for _, u := range users {
+		if m, ok := u.(map[string]any); ok {
+			list = append(list, presenceFromMap(m))
+		}
+	}
+	return list
+}
+
+func (c *Client) presence() Presence {
+	return Presence{
+		ConnectionId: c.id,
+		UserId:       c.userUuid,
+		Email:        c.email,
+	}
+}
+
+// Everyone connected to the room, on this instance and any other
+func (r *Room) presence() []Presence {
+	list := r.localPresence()
+	for _, p := range r.remotePresence {
+		list = append(list, p)
+	}
+	return list
+}
+
+func (r *Room) localPresence() []Presence {
+	list := make([]Presence, 0, len(r.Clients))
+	for c := range r.Clients {
+		list = append(list, c.presence())
+	}
+	return list
+}
+
+// Throttles ephemeral events per client, only called from the client's reader
+func (c *Client) allowEphemeral(event string) bool {
+	limiter, exists := c.limiters[event]
+	if !exists {
+		interval := config.WebsocketCursorInterval()
+		if event == "viewport" {
+			interval = config.WebsocketViewportInterval()
+		}
+		limiter = rate.NewLimiter(rate.Every(interval), 1)
+		c.limiters[event] = limiter
+	}
+
+	return limiter.Allow()
+}
@@ -0,0 +1,126 @@
+package websocket_service
+
+import (
+	"qolboard-api/config"
+	service "qolboard-api/services"
+	canvas_service "qolboard-api/services/canvas"
+	"qolboard-api/services/logging"
+
+	"github.com/jesse-rb/imissphp-go"
+)
+
+// Where a reconnecting client left off, from the last "resume" event and message seq it received
+type Resume struct {
+	Epoch   string
+	LastSeq uint64
+}
+
+// Whether a message gets a sequence number and is kept for replay, cursors and presence go stale too quickly to be worth replaying
+func replayable(event string) bool {
+	return !imissphp.InArray(event, ephemeralEvents) && !imissphp.InArray(event, serverEvents)
+}
+
+// Numbers msg and keeps it in the room's replay buffer, callers must hold r.mu.
+// Changes are numbered in the same critical section that applies them, so the seq order is the order they were applied in
+func (r *Room) stamp(msg RoomMessage) RoomMessage {
+	if !replayable(msg.Event) {
+		return msg
+	}
+
+	r.seq++
+	msg.Seq = r.seq
+
+	r.replay = append(r.replay, msg)
+	if overflow := len(r.replay) - config.WebsocketReplayBufferSize(); overflow > 0 {
+		r.replay = append(r.replay[:0:0], r.replay[overflow:]...)
+	}
+
+	return msg
+}
+
+// Messages numbered after lastSeq, false when some of them have already rolled out of the buffer, callers must hold r.mu
+func (r *Room) missedSince(lastSeq uint64) ([]RoomMessage, bool) {
+	if lastSeq > r.seq {
+		return nil, false
+	}
+	if lastSeq == r.seq {
+		return []RoomMessage{}, true
+	}
+	if len(r.replay) == 0 || r.replay[0].Seq > lastSeq+1 {
+		return nil, false
+	}
+
+	return r.replay[len(r.replay)-int(r.seq-lastSeq):], true
+}
+
+// The full live canvas, numbered with the room's seq so the client can resume from it later, callers must hold r.mu.
+// Lazy snapshots have no pieces, only the layers and bounds the client needs to load them a viewport at a time
+func (r *Room) snapshot(lazy bool) RoomMessage {
+	canvas := *r.Canvas
+	canvas.CanvasData = r.copyCanvasData()
+	if lazy && canvas.CanvasData.PiecesManager != nil {
+		canvas.CanvasData.PiecesManager.Pieces = make([]*canvas_service.PieceData, 0)
+	}
+
+	return RoomMessage{
+		Seq:   r.seq,
+		Event: "update-canvas-data",
+		Data:  service.ToMapStringAny(canvas),
+	}
+}
+
+// Sends the room's clients every message numbered since the last delivery, in seq order.
+// Messages are numbered by whoever applied them but reach the event loop in any order, so whichever arrives first delivers the rest.
+// Only called from the rooms manager's event loop
+func (r *Room) deliverStamped() {
+	r.mu.Lock()
+	pending, ok := r.missedSince(r.delivered)
+	r.delivered = r.seq
+	r.mu.Unlock()
+	if !ok {
+		logging.LogError("WebSocket", "Messages rolled out of the replay buffer before they were delivered", r.Canvas.ID)
+		return
+	}
+
+	for _, msg := range pending {
+		r.deliver(msg)
+	}
+}
+
+// Brings a newly joined client up to date, replaying what it missed when possible and sending a full snapshot otherwise.
+// Called before the client's writer starts, so everything sent here has to fit in its send queue.
+func (r *Room) catchUp(client *Client, resume *Resume, lazy bool) {
+	r.mu.Lock()
+	var missed []RoomMessage
+	replayed := false
+	if resume != nil && resume.Epoch == r.epoch {
+		missed, replayed = r.missedSince(resume.LastSeq)
+	}
+	if replayed && len(missed) >= cap(client.chSend)-len(client.chSend) {
+		replayed = false // Too much to queue, a snapshot brings the client up to date just the same
+	}
+	var snapshot RoomMessage
+	if !replayed {
+		snapshot = r.snapshot(lazy)
+	}
+	// Changes up to here may still be on their way through the event loop, the client already has them
+	client.since = r.seq
+	r.mu.Unlock()
+
+	client.chSend <- RoomMessage{
+		Event: "resume",
+		Data: map[string]any{
+			"epoch":    r.epoch,
+			"seq":      client.since,
+			"replayed": replayed,
+		},
+	}
+
+	if !replayed {
+		client.chSend <- snapshot
+		return
+	}
+	for _, msg := range missed {
+		client.chSend <- msg
+	}
+}
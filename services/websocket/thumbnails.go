@@ -0,0 +1,85 @@
+package websocket_service
+
+import (
+	database_config "qolboard-api/config/database"
+	model "qolboard-api/models"
+	canvas_thumbnail_model "qolboard-api/models/canvas_thumbnail"
+	"qolboard-api/services/database"
+	"qolboard-api/services/logging"
+	"sync"
+)
+
+// Renders the thumbnails of saved rooms one at a time away from the rooms, so saving a room never waits on rendering.
+// A canvas queued again before its turn is only rendered once, with the data it was last queued with
+type thumbnailQueue struct {
+	mu      sync.Mutex
+	pending map[string]*model.Canvas // Keyed by canvas id, guarded by mu
+	order   []string                 // Canvas ids in the order they were first queued, guarded by mu
+	wake    chan struct{}
+	render  func(canvas *model.Canvas) error
+}
+
+func newThumbnailQueue(render func(canvas *model.Canvas) error) *thumbnailQueue {
+	return &thumbnailQueue{
+		pending: make(map[string]*model.Canvas),
+		order:   make([]string, 0),
+		wake:    make(chan struct{}, 1),
+		render:  render,
+	}
+}
+
+// Queues canvas for a fresh thumbnail, canvas must not be changed after
+func (q *thumbnailQueue) queue(canvas *model.Canvas) {
+	q.mu.Lock()
+	if _, queued := q.pending[canvas.ID]; !queued {
+		q.order = append(q.order, canvas.ID)
+	}
+	q.pending[canvas.ID] = canvas
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default: // Already awake, it will find this one too
+	}
+}
+
+func (q *thumbnailQueue) next() (*model.Canvas, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.order) == 0 {
+		return nil, false
+	}
+	id := q.order[0]
+	q.order = q.order[1:]
+	canvas := q.pending[id]
+	delete(q.pending, id)
+	return canvas, true
+}
+
+func (q *thumbnailQueue) Run() {
+	for range q.wake {
+		for canvas, ok := q.next(); ok; canvas, ok = q.next() {
+			err := q.render(canvas)
+			if err != nil {
+				logging.LogError("WebSocket", "Error refreshing canvas thumbnail", err)
+			}
+		}
+	}
+}
+
+// Thumbnails are a cache, a room's is refreshed in a transaction of its own
+func refreshThumbnail(canvas *model.Canvas) error {
+	tx, err := database_config.DB(nil)
+	if err != nil {
+		return err
+	}
+	defer database.StandardDeferRollback(tx)
+
+	err = canvas_thumbnail_model.Refresh(tx, canvas)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,147 @@
+package websocket_service
+
+import (
+	"errors"
+	"qolboard-api/config"
+	service "qolboard-api/services"
+	canvas_service "qolboard-api/services/canvas"
+	"reflect"
+)
+
+// Events which undo or redo the sender's own piece changes
+var historyEvents = []string{
+	"undo",
+	"redo",
+}
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// A piece change made by a user, before is nil for added pieces and after is nil for removed pieces
+type operation struct {
+	before *canvas_service.PieceData
+	after  *canvas_service.PieceData
+	index  int // Where the piece was in the drawing order, removed pieces are added back there
+}
+
+// A user's undo and redo stacks within a room, guarded by the room's mu
+type history struct {
+	undo []operation
+	redo []operation
+}
+
+func (r *Room) historyFor(userUuid string) *history {
+	h, exists := r.histories[userUuid]
+	if !exists {
+		h = &history{
+			undo: make([]operation, 0),
+			redo: make([]operation, 0),
+		}
+		r.histories[userUuid] = h
+	}
+	return h
+}
+
+// Remembers a piece change so the user can undo it, callers must hold room.mu
+func (r *Room) record(userUuid string, op operation) {
+	h := r.historyFor(userUuid)
+	h.undo = append(h.undo, op)
+	if overflow := len(h.undo) - config.WebsocketUndoHistorySize(); overflow > 0 {
+		h.undo = append(h.undo[:0:0], h.undo[overflow:]...)
+	}
+	h.redo = h.redo[:0] // A new change starts a new branch, the old one can no longer be redone
+}
+
+func (r *Room) findPiece(id string) *canvas_service.PieceData {
+	pm := r.Canvas.CanvasData.PiecesManager
+	if pm == nil {
+		return nil
+	}
+	index := pm.IndexOf(id)
+	if index < 0 {
+		return nil
+	}
+	return pm.Pieces[index]
+}
+
+// The event and data that moves a piece from one state to another, false when the piece is no longer in the from state,
+// e.g. someone else changed or removed it since, so the operation can't be reverted without clobbering their change,
+// or when either state is on a layer which is locked or no longer exists. Pieces added back go at index in the drawing order.
+// Callers must hold room.mu
+func (r *Room) transition(from *canvas_service.PieceData, to *canvas_service.PieceData, index int) (string, map[string]any, bool) {
+	// Pieces on locked layers stay as they are, undo included
+	for _, piece := range []*canvas_service.PieceData{from, to} {
+		if piece != nil && r.Canvas.CanvasData.PiecesManager.CheckLayerEditable(piece.LayerID) != nil {
+			return "", nil, false
+		}
+	}
+
+	switch {
+	case from == nil:
+		if r.findPiece(to.ID) != nil {
+			return "", nil, false
+		}
+		data := service.ToMapStringAny(to)
+		data["index"] = index
+		return "add-piece", data, true
+
+	case !reflect.DeepEqual(r.findPiece(from.ID), from):
+		return "", nil, false
+
+	case to == nil:
+		return "remove-piece", map[string]any{"id": from.ID}, true
+
+	default:
+		return "update-piece", service.ToMapStringAny(to), true
+	}
+}
+
+// Reverts the user's most recent piece change which can still be reverted, returns the numbered message for the event that was applied
+func (r *Room) undo(userUuid string, email string) (RoomMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := r.historyFor(userUuid)
+	for len(h.undo) > 0 {
+		op := h.undo[len(h.undo)-1]
+		h.undo = h.undo[:len(h.undo)-1]
+
+		event, data, ok := r.transition(op.after, op.before, op.index)
+		if !ok {
+			continue // Stale, skip to the change before it
+		}
+		err := r.applyEvent(event, data)
+		if err != nil {
+			return RoomMessage{}, err
+		}
+		h.redo = append(h.redo, op)
+		return r.stamp(RoomMessage{room: r, Event: event, Email: email, Data: data}), nil
+	}
+
+	return RoomMessage{}, ErrNothingToUndo
+}
+
+// Re-applies the user's most recently undone piece change, returns the numbered message for the event that was applied
+func (r *Room) redo(userUuid string, email string) (RoomMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := r.historyFor(userUuid)
+	for len(h.redo) > 0 {
+		op := h.redo[len(h.redo)-1]
+		h.redo = h.redo[:len(h.redo)-1]
+
+		event, data, ok := r.transition(op.before, op.after, op.index)
+		if !ok {
+			continue
+		}
+		err := r.applyEvent(event, data)
+		if err != nil {
+			return RoomMessage{}, err
+		}
+		h.undo = append(h.undo, op)
+		return r.stamp(RoomMessage{room: r, Event: event, Email: email, Data: data}), nil
+	}
+
+	return RoomMessage{}, ErrNothingToRedo
+}
@@ -2,23 +2,32 @@ package websocket_service
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"qolboard-api/config"
 	database_config "qolboard-api/config/database"
 	model "qolboard-api/models"
+	canvas_version_model "qolboard-api/models/canvas_version"
 	service "qolboard-api/services"
-	auth_service "qolboard-api/services/auth"
 	canvas_service "qolboard-api/services/canvas"
+	"qolboard-api/services/database"
 	"qolboard-api/services/logging"
 	response_service "qolboard-api/services/response"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/jesse-rb/imissphp-go"
+	"golang.org/x/time/rate"
 )
 
 // Websocket upgrader
+// How often a room saves its canvas while anyone is connected, whether or not it changed
+const RoomSaveInterval = 30 * time.Second
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -29,87 +38,229 @@ var upgrader = websocket.Upgrader{
 
 type dataChJoin struct {
 	userUuid string
+	email    string
+	role     string
+	resume   *Resume
+	lazy     bool // Leave the pieces out of the joining snapshot, the client loads them with query-viewport
 	canvas   *model.Canvas
 	conn     *websocket.Conn
 	chResume chan *Client
 }
 
 type RoomsManager struct {
-	roomsMap    map[string]*Room
-	chJoin      chan *dataChJoin
-	chLeave     chan *Client
-	chBroadcast chan RoomMessage
+	id              string // Identifies this manager to the backend, unique across API instances
+	backend         Backend
+	roomsMap        map[string]*Room
+	chJoin          chan *dataChJoin
+	chLeave         chan *Client
+	chBroadcast     chan RoomMessage
+	chSetCanvasData chan *model.Canvas
+	chSetRole       chan *dataChSetRole
+	chPresence      chan *dataChPresence
+	chCanvasData    chan *dataChCanvasData
+	chPieces        chan *dataChPieces
+	chPublish       chan Envelope
+	thumbnails      *thumbnailQueue
+}
+
+type dataChCanvasData struct {
+	canvas   *model.Canvas
+	chResult chan canvas_service.CanvasData
+}
+
+type dataChPieces struct {
+	canvas   *model.Canvas
+	view     canvas_service.Bounds
+	chResult chan []*canvas_service.PieceData
+}
+
+type dataChSetRole struct {
+	canvasId string
+	userUuid string
+	role     string
 }
 
 type Room struct {
+	mu      sync.Mutex // Guards Canvas, client readers and the save loop touch it concurrently
+	manager *RoomsManager
 	Canvas  *model.Canvas
+	applied int // Number of changes applied since the room loaded the canvas, guarded by mu
 	Clients map[*Client]bool
-	chSave  chan bool
-	chClose chan bool
+	// Clients connected to this canvas through other rooms managers, keyed by connection id
+	remotePresence map[string]Presence
+	// Each user's piece changes for undo and redo, keyed by user uuid and guarded by mu
+	histories map[string]*history
+	// Where the pieces are, built by the first viewport query and kept up to date with piece changes after, guarded by mu
+	index *canvas_service.SpatialIndex
+	// Identifies this room's numbering, seq starts again whenever a room is created
+	epoch     string
+	seq       uint64        // Number of the last replayable message, guarded by mu
+	replay    []RoomMessage // Most recent replayable messages, guarded by mu
+	delivered uint64        // Number of the last message sent to the clients, only touched by the rooms manager's event loop
+	chSave    chan bool
+	chClose   chan bool
 }
 
 type Client struct {
+	id       string // Identifies this connection, a user may be connected more than once
 	userUuid string
+	email    string
+	role     string // Guarded by the room's mu, the owner may change it while connected
 	room     *Room
 	conn     *websocket.Conn
 	chSend   chan RoomMessage
+	limiters map[string]*rate.Limiter // Per event throttles for ephemeral events
+	leave    sync.Once                // Both a close frame and the reader stopping leave the room
+	since    uint64                   // Seq the client caught up to when joining, only touched by the rooms manager's event loop
 }
 
 type RoomMessage struct {
-	author *Client
-	room   *Room
-	Event  string         `json:"event" binding:"required"`
-	Email  string         `json:"email" binding:"required"`
-	Data   map[string]any `json:"data" binding:"required"`
+	author   *Client
+	room     *Room
+	canvasId string         // Used to find the room when room is not set
+	Seq      uint64         `json:"seq,omitempty"` // Set by the room when broadcasting, clients send the last one they saw when reconnecting
+	Event    string         `json:"event" binding:"required"`
+	Email    string         `json:"email" binding:"required"`
+	Data     map[string]any `json:"data" binding:"required"`
+}
+
+// Events which mutate the canvas data, these are applied by every room the event reaches
+var mutatingEvents = []string{
+	"add-piece",
+	"update-piece",
+	"remove-piece",
+	"update-canvas-data",
+	"add-layer",
+	"update-layer",
+	"reorder-layers",
+}
+
+// Events only the server may send, e.g. clients can not claim someone joined or left
+var serverEvents = []string{
+	"presence",
+	"presence-join",
+	"presence-leave",
+	"resume",
+	"ack",
+	"add-piece-ack",
+	"history-empty",
+	"comment-add",
+	"comment-update",
+	"comment-remove",
+	"viewport-pieces",
+	"error",
 }
 
-var rm *RoomsManager = NewRoomsManager()
+var ErrViewerCanNotChange = errors.New("viewers can not change the canvas")
 
-func init() {
+var rm *RoomsManager
+
+// Starts the process wide rooms manager, must be called before serving any websocket connections
+func Start(backend Backend) {
+	rm = NewRoomsManager(backend)
 	go rm.Run()
 }
 
-func NewRoomsManager() *RoomsManager {
+func NewRoomsManager(backend Backend) *RoomsManager {
+	id, err := service.GenerateCode(16)
+	if err != nil {
+		panic(err)
+	}
+
 	return &RoomsManager{
-		roomsMap:    make(map[string]*Room),
-		chJoin:      make(chan *dataChJoin),
-		chLeave:     make(chan *Client),
-		chBroadcast: make(chan RoomMessage),
+		id:              id,
+		backend:         backend,
+		roomsMap:        make(map[string]*Room),
+		chJoin:          make(chan *dataChJoin),
+		chLeave:         make(chan *Client),
+		chBroadcast:     make(chan RoomMessage),
+		chSetCanvasData: make(chan *model.Canvas),
+		chSetRole:       make(chan *dataChSetRole),
+		chPresence:      make(chan *dataChPresence),
+		chCanvasData:    make(chan *dataChCanvasData),
+		chPieces:        make(chan *dataChPieces),
+		chPublish:       make(chan Envelope, 256),
+		thumbnails:      newThumbnailQueue(refreshThumbnail),
 	}
 }
 
-func NewRoom(canvas *model.Canvas) *Room {
+func NewRoom(manager *RoomsManager, canvas *model.Canvas) *Room {
+	epoch, err := service.GenerateCode(16)
+	if err != nil {
+		panic(err)
+	}
+
+	// Canvases read from the database already have layers, ones handed over by other rooms managers may not
+	canvas.CanvasData.BackfillLayers()
+
 	return &Room{
+		manager: manager,
 		Canvas:  canvas,
 		Clients: make(map[*Client]bool),
+		epoch:   epoch,
+		replay:  make([]RoomMessage, 0),
 		chSave:  make(chan bool),
 		chClose: make(chan bool),
+
+		remotePresence: make(map[string]Presence),
+		histories:      make(map[string]*history),
 	}
 }
 
-func NewClient(userUuid string, room *Room, conn *websocket.Conn) *Client {
+func NewClient(userUuid string, email string, role string, room *Room, conn *websocket.Conn) *Client {
+	id, err := service.GenerateCode(16)
+	if err != nil {
+		panic(err)
+	}
+
 	client := &Client{
+		id:       id,
 		userUuid: userUuid,
+		email:    email,
+		role:     role,
 		room:     room,
 		chSend:   make(chan RoomMessage, 256), // Allow for some buffer
 		conn:     conn,
+		limiters: make(map[string]*rate.Limiter),
 	}
 
 	return client
 }
 
 func BroadcastCanvas(canvas *model.Canvas) {
+	rm.BroadcastCanvas(canvas)
+}
+
+// Everyone in the room gets the canvas, viewers included, so invitation codes are never part of it
+func (rm *RoomsManager) BroadcastCanvas(canvas *model.Canvas) {
 	data := response_service.BuildResponse(*canvas)
 	if v, ok := data.(map[string]any); ok {
+		delete(v, "canvas_shared_invitations")
 		msg := RoomMessage{
-			author: nil,
-			room:   rm.getRoom(canvas),
-			Event:  "get",
-			Email:  "",
-			Data:   v,
+			author:   nil,
+			canvasId: canvas.ID,
+			Event:    "get",
+			Email:    "",
+			Data:     v,
 		}
 
-		Broadcast(msg)
+		rm.Broadcast(msg)
+	}
+}
+
+// Tells everyone in the comment's canvas room about a comment change made through the REST API, event is one of the comment-* server events
+func BroadcastComment(event string, comment *model.CanvasComment) {
+	rm.BroadcastComment(event, comment)
+}
+
+func (rm *RoomsManager) BroadcastComment(event string, comment *model.CanvasComment) {
+	data := response_service.BuildResponse(*comment)
+	if v, ok := data.(map[string]any); ok {
+		rm.Broadcast(RoomMessage{
+			canvasId: comment.CanvasId,
+			Event:    event,
+			Data:     v,
+		})
 	}
 }
 
@@ -129,14 +280,34 @@ func (rm *RoomsManager) getRoom(canvas *model.Canvas) *Room {
 	if room, exists := rm.roomsMap[canvas.ID]; exists {
 		return room
 	}
-	room := NewRoom(canvas)
+	room := NewRoom(rm, canvas)
 	rm.roomsMap[canvas.ID] = room
 	go room.Run()
 
+	// The canvas may already be live on another instance with changes that are not saved yet
+	rm.publish(Envelope{
+		Kind:     envelopeKindSyncRequest,
+		CanvasId: canvas.ID,
+	})
+
 	return room
 }
 
 func (rm *RoomsManager) Run() {
+	chRemote := rm.backend.Subscribe()
+
+	// Publish from a separate goroutine so a slow backend never holds up the event loop, a single goroutine keeps envelopes in order
+	go func() {
+		for env := range rm.chPublish {
+			err := rm.backend.Publish(env)
+			if err != nil {
+				logging.LogError("WebSocket", "Failed to publish to websocket backend", err)
+			}
+		}
+	}()
+
+	go rm.thumbnails.Run()
+
 	// Event loop for our rooms manager, only one of these events should run at a given time
 	for {
 		select {
@@ -144,8 +315,23 @@ func (rm *RoomsManager) Run() {
 		case joinRoomData := <-rm.chJoin:
 			logging.LogDebug("(WS event loop)", "receiving join", nil)
 			room := rm.getRoom(joinRoomData.canvas)
-			client := NewClient(joinRoomData.userUuid, room, joinRoomData.conn)
+			client := NewClient(joinRoomData.userUuid, joinRoomData.email, joinRoomData.role, room, joinRoomData.conn)
+
+			// Tell the new client who is already here, then tell everyone else about the new client
+			client.chSend <- RoomMessage{
+				Event: "presence",
+				Data:  presenceListToMap(room.presence()),
+			}
+			room.catchUp(client, joinRoomData.resume, joinRoomData.lazy)
 			room.addClient(client)
+			rm.broadcastAndPublish(RoomMessage{
+				author: client,
+				room:   room,
+				Event:  "presence-join",
+				Email:  client.email,
+				Data:   client.presence().toMap(),
+			})
+
 			joinRoomData.chResume <- client // Send the client back to the websocket connection controller action
 
 		// A client leaves a room
@@ -156,6 +342,13 @@ func (rm *RoomsManager) Run() {
 			close(client.chSend)
 			client.conn.Close()
 
+			rm.broadcastAndPublish(RoomMessage{
+				room:  room,
+				Event: "presence-leave",
+				Email: client.email,
+				Data:  client.presence().toMap(),
+			})
+
 			if !room.hasClients() {
 				// If the room is now empty, do some cleanup by deleting the room
 				room.chSave <- true
@@ -164,29 +357,306 @@ func (rm *RoomsManager) Run() {
 			}
 
 		case msg := <-rm.chBroadcast:
-			for c := range msg.room.Clients {
-				if msg.author == c {
-					continue // Don't send the message back to the author
-				}
+			rm.broadcastAndPublish(msg)
+
+		// Someone wants to know who is connected to a canvas
+		case presenceData := <-rm.chPresence:
+			list := make([]Presence, 0)
+			if room, exists := rm.roomsMap[presenceData.canvasId]; exists {
+				list = room.presence()
+			}
+			presenceData.chResult <- list
+
+		// Someone wants the freshest canvas data, which may not be saved yet
+		case canvasData := <-rm.chCanvasData:
+			if room, exists := rm.roomsMap[canvasData.canvas.ID]; exists {
+				canvasData.chResult <- room.GetCanvasData()
+			} else {
+				canvasData.chResult <- canvasData.canvas.CanvasData
+			}
+
+		// Someone wants the freshest pieces in a viewport
+		case piecesData := <-rm.chPieces:
+			if room, exists := rm.roomsMap[piecesData.canvas.ID]; exists {
+				piecesData.chResult <- room.PiecesIn(piecesData.view)
+			} else {
+				pm := piecesData.canvas.CanvasData.PiecesManager
+				piecesData.chResult <- pm.PiecesIn(pm.SpatialIndex(), piecesData.view)
+			}
+
+		// Canvas data was replaced outside of the room (e.g. a version restore)
+		case canvas := <-rm.chSetCanvasData:
+			// Build the map before the room takes ownership of the canvas data, clients may mutate it straight after
+			data := service.ToMapStringAny(canvas)
+			rm.setCanvasData(canvas, data)
+			rm.publish(Envelope{
+				Kind:     envelopeKindCanvasData,
+				CanvasId: canvas.ID,
+				Data:     data,
+			})
+
+		// A collaborator's role was changed by the canvas owner
+		case setRoleData := <-rm.chSetRole:
+			rm.setRole(setRoleData)
+			rm.publish(Envelope{
+				Kind:     envelopeKindRole,
+				CanvasId: setRoleData.canvasId,
+				Data: map[string]any{
+					"user_id": setRoleData.userUuid,
+					"role":    setRoleData.role,
+				},
+			})
+
+		// Something happened on another rooms manager, possibly in another API instance
+		case env, ok := <-chRemote:
+			if !ok {
+				logging.LogError("WebSocket", "Websocket backend subscription closed", nil)
+				chRemote = nil // Keep serving local rooms, a nil channel is never selected
+				break
+			}
+			rm.handleRemote(env)
+		}
+	}
+}
+
+// Broadcasts to the room's clients here, and to the room on every other rooms manager
+func (rm *RoomsManager) broadcastAndPublish(msg RoomMessage) {
+	rm.broadcast(msg)
+
+	canvasId := msg.canvasId
+	if msg.room != nil {
+		canvasId = msg.room.Canvas.ID
+	}
+	rm.publish(Envelope{
+		Kind:     envelopeKindMessage,
+		CanvasId: canvasId,
+		Event:    msg.Event,
+		Email:    msg.Email,
+		Data:     msg.Data,
+	})
+}
+
+// Queues an envelope for the backend, drops it rather than blocking the event loop when the queue is full
+func (rm *RoomsManager) publish(env Envelope) {
+	env.Origin = rm.id
+	select {
+	case rm.chPublish <- env:
+	default:
+		logging.LogError("WebSocket", "Websocket backend publish queue is full, dropping envelope", env.Kind)
+	}
+}
+
+func (rm *RoomsManager) handleRemote(env Envelope) {
+	if env.Origin == rm.id || (env.Target != "" && env.Target != rm.id) {
+		return
+	}
+
+	room, exists := rm.roomsMap[env.CanvasId]
+	if !exists {
+		return // Nobody is connected to this canvas here
+	}
+
+	switch env.Kind {
+	case envelopeKindMessage:
+		switch env.Event {
+		case "presence-join":
+			p := presenceFromMap(env.Data)
+			room.remotePresence[p.ConnectionId] = p
+		case "presence-leave":
+			delete(room.remotePresence, presenceFromMap(env.Data).ConnectionId)
+		}
+
+		msg := RoomMessage{
+			room:  room,
+			Event: env.Event,
+			Email: env.Email,
+			Data:  env.Data,
+		}
+		if imissphp.InArray(env.Event, mutatingEvents) {
+			room.mu.Lock()
+			err := room.applyEvent(env.Event, env.Data)
+			if err == nil {
+				msg = room.stamp(msg)
+			}
+			room.mu.Unlock()
+			if err != nil {
+				logging.LogError("WebSocket", "Failed to apply remote event", err)
+				return
+			}
+		}
+
+		rm.broadcast(msg)
+
+	case envelopeKindCanvasData:
+		bytes, err := json.Marshal(env.Data)
+		if err != nil {
+			logging.LogError("WebSocket", "Could not marshal remote canvas", err)
+			return
+		}
+		canvas := &model.Canvas{}
+		err = json.Unmarshal(bytes, canvas)
+		if err != nil {
+			logging.LogError("WebSocket", "Remote canvas is invalid", err)
+			return
+		}
+
+		if env.Target != "" {
+			// A sync reply, only useful while the room still holds the data it loaded from the database.
+			// Once anything was applied here the reply may be older than what we have, so keep ours.
+			room.mu.Lock()
+			applied := room.applied
+			room.mu.Unlock()
+			if applied > 0 {
+				return
+			}
+		}
+		rm.setCanvasData(canvas, env.Data)
+
+	case envelopeKindRole:
+		userUuid, _ := env.Data["user_id"].(string)
+		role, _ := env.Data["role"].(string)
+		rm.setRole(&dataChSetRole{
+			canvasId: env.CanvasId,
+			userUuid: userUuid,
+			role:     role,
+		})
+
+	case envelopeKindPresence:
+		// Reply to our sync request, let our clients know about everyone connected elsewhere
+		for _, p := range presenceListFromMap(env.Data) {
+			if _, known := room.remotePresence[p.ConnectionId]; known {
+				continue
+			}
+			room.remotePresence[p.ConnectionId] = p
+			rm.broadcast(RoomMessage{
+				room:  room,
+				Event: "presence-join",
+				Email: p.Email,
+				Data:  p.toMap(),
+			})
+		}
+
+	case envelopeKindSyncRequest:
+		if local := room.localPresence(); len(local) > 0 {
+			rm.publish(Envelope{
+				Target:   env.Origin,
+				Kind:     envelopeKindPresence,
+				CanvasId: env.CanvasId,
+				Data:     presenceListToMap(local),
+			})
+		}
+
+		room.mu.Lock()
+		canvas := *room.Canvas
+		applied := room.applied
+		room.mu.Unlock()
+		if applied == 0 {
+			return // Nothing beyond what the requester already loaded from the database
+		}
+
+		// Answer with our live copy, only the requesting manager applies it
+		canvas.CanvasData = room.GetCanvasData()
+
+		rm.publish(Envelope{
+			Target:   env.Origin,
+			Kind:     envelopeKindCanvasData,
+			CanvasId: env.CanvasId,
+			Data:     service.ToMapStringAny(canvas),
+		})
+	}
+}
+
+// Hands canvas' data over to its room, data is what gets sent to the room's clients
+func (rm *RoomsManager) setCanvasData(canvas *model.Canvas, data map[string]any) {
+	room, exists := rm.roomsMap[canvas.ID]
+	if !exists {
+		return // Nobody is connected, nothing to update
+	}
+
+	room.mu.Lock()
+	room.Canvas.CanvasData = canvas.CanvasData
+	room.applied++
+	room.index = nil // Rebuilt from the new pieces when next needed
+	msg := room.stamp(RoomMessage{
+		room:  room,
+		Event: "update-canvas-data",
+		Data:  data,
+	})
+	room.mu.Unlock()
+
+	rm.broadcast(msg)
+}
+
+func (rm *RoomsManager) setRole(setRoleData *dataChSetRole) {
+	room, exists := rm.roomsMap[setRoleData.canvasId]
+	if !exists {
+		return
+	}
+
+	room.mu.Lock()
+	for c := range room.Clients {
+		if c.userUuid == setRoleData.userUuid {
+			c.role = setRoleData.role
+		}
+	}
+	room.mu.Unlock()
+}
+
+func (rm *RoomsManager) broadcast(msg RoomMessage) {
+	if msg.room == nil {
+		room, exists := rm.roomsMap[msg.canvasId]
+		if !exists {
+			return
+		}
+		msg.room = room
+	}
+
+	if !replayable(msg.Event) {
+		msg.room.deliver(msg)
+		return
+	}
+	if msg.Seq == 0 {
+		// Changes are numbered where they are applied, anything else is numbered as it reaches the event loop
+		msg.room.mu.Lock()
+		msg.room.stamp(msg)
+		msg.room.mu.Unlock()
+	}
+	msg.room.deliverStamped()
+}
+
+// Sends msg to each of the room's clients, only called from the rooms manager's event loop
+func (room *Room) deliver(msg RoomMessage) {
+	for c := range room.Clients {
+		if msg.author == c {
+			if msg.Seq > 0 {
+				// Don't send the message back to the author, but do let them know its seq
 				select {
-				// Attempt to send message to the cleint (YAY go channels!)
-				case c.chSend <- msg:
-				// client's send queue is full, better not hold up our entire event loop...
+				case c.chSend <- RoomMessage{Seq: msg.Seq, Event: "ack", Data: map[string]any{"event": msg.Event}}:
 				default:
-					logging.LogDebug("(WS event loop)", "skipping... client send channel is FULL", map[string]any{
-						"available cap": cap(c.chSend),
-						"queued len":    len(c.chSend),
-					})
-					continue
 				}
 			}
+			continue
+		}
+		if msg.Seq > 0 && msg.Seq <= c.since {
+			continue // Already part of what the client caught up with when joining
+		}
+		select {
+		// Attempt to send message to the cleint (YAY go channels!)
+		case c.chSend <- msg:
+		// client's send queue is full, better not hold up our entire event loop...
+		default:
+			logging.LogDebug("(WS event loop)", "skipping... client send channel is FULL", map[string]any{
+				"available cap": cap(c.chSend),
+				"queued len":    len(c.chSend),
+			})
+			continue
 		}
 	}
 }
 
 func (room *Room) Run() {
 	// Ticker to save canvas every interval
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(RoomSaveInterval)
 	quit := make(chan struct{})
 	go func() {
 		for {
@@ -205,18 +675,7 @@ func (room *Room) Run() {
 		select {
 		case meh := <-room.chSave:
 			logging.LogDebug("WebSocket", "Saving canvas triggered", meh)
-			tx, err := database_config.DB(nil)
-			defer tx.Rollback()
-			if err != nil {
-				break
-			}
-			err = room.Canvas.SystemUpdate(tx)
-			if err != nil {
-				logging.LogError("WebSocket", "Error saving canvas data after receiving message", err)
-				break
-			}
-			tx.Commit() // Commit the transaction
-			logging.LogInfo("WebSocket", "Canvas saved by websocket room", nil)
+			room.save()
 
 		case <-room.chClose:
 			return
@@ -224,104 +683,552 @@ func (room *Room) Run() {
 	}
 }
 
+// Saves the room's canvas, then snapshots a version and refreshes the thumbnail. Those each get a transaction of their own,
+// so a failed snapshot or thumbnail never loses the save
+func (room *Room) save() {
+	err := room.saveCanvas()
+	if err != nil {
+		logging.LogError("WebSocket", "Error saving canvas data after receiving message", err)
+		return
+	}
+	logging.LogInfo("WebSocket", "Canvas saved by websocket room", nil)
+
+	err = room.snapshotVersion()
+	if err != nil {
+		logging.LogError("WebSocket", "Error snapshotting canvas version", err)
+	}
+
+	room.queueThumbnail()
+}
+
+func (room *Room) saveCanvas() error {
+	tx, err := database_config.DB(nil)
+	if err != nil {
+		return err
+	}
+	defer database.StandardDeferRollback(tx)
+
+	room.mu.Lock()
+	err = room.Canvas.SystemUpdate(tx)
+	room.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Rendering can take a while on big canvases, so it happens on the rooms manager's thumbnail queue rather than here
+func (room *Room) queueThumbnail() {
+	room.mu.Lock()
+	canvas := *room.Canvas
+	canvas.CanvasData = room.copyCanvasData()
+	room.mu.Unlock()
+	room.manager.thumbnails.queue(&canvas)
+}
+
+func (room *Room) snapshotVersion() error {
+	tx, err := database_config.DB(nil)
+	if err != nil {
+		return err
+	}
+	defer database.StandardDeferRollback(tx)
+
+	err = canvas_version_model.Snapshot(tx, room.Canvas.ID, nil, model.CanvasVersionSourceWebsocket, config.CanvasVersionsWebsocketInterval())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // Allow broadcasting to a room externally
 func Broadcast(msg RoomMessage) {
+	rm.Broadcast(msg)
+}
+
+func (rm *RoomsManager) Broadcast(msg RoomMessage) {
 	// Broadcast message to all clients in the room
 	rm.chBroadcast <- msg
 }
 
-func Join(userUuid string, canvas *model.Canvas, conn *websocket.Conn, chResume chan *Client) {
+// Replaces the canvas data of the canvas' live room, if there is one, and pushes it to all connected clients
+func SetCanvasData(canvas *model.Canvas) {
+	rm.SetCanvasData(canvas)
+}
+
+func (rm *RoomsManager) SetCanvasData(canvas *model.Canvas) {
+	rm.chSetCanvasData <- canvas
+}
+
+// Updates the role of a user's live connections to a canvas
+func SetRole(canvasId string, userUuid string, role string) {
+	rm.SetRole(canvasId, userUuid, role)
+}
+
+func (rm *RoomsManager) SetRole(canvasId string, userUuid string, role string) {
+	rm.chSetRole <- &dataChSetRole{
+		canvasId: canvasId,
+		userUuid: userUuid,
+		role:     role,
+	}
+}
+
+// The canvas' live data when it has a room here, otherwise the data it was loaded with
+func GetCanvasData(canvas *model.Canvas) canvas_service.CanvasData {
+	return rm.GetCanvasData(canvas)
+}
+
+func (rm *RoomsManager) GetCanvasData(canvas *model.Canvas) canvas_service.CanvasData {
+	chResult := make(chan canvas_service.CanvasData, 1)
+	rm.chCanvasData <- &dataChCanvasData{
+		canvas:   canvas,
+		chResult: chResult,
+	}
+	return <-chResult
+}
+
+// The pieces of a canvas intersecting the view, from its room when someone is connected so unsaved changes are included
+func GetPiecesIn(canvas *model.Canvas, view canvas_service.Bounds) []*canvas_service.PieceData {
+	return rm.GetPiecesIn(canvas, view)
+}
+
+func (rm *RoomsManager) GetPiecesIn(canvas *model.Canvas, view canvas_service.Bounds) []*canvas_service.PieceData {
+	chResult := make(chan []*canvas_service.PieceData, 1)
+	rm.chPieces <- &dataChPieces{
+		canvas:   canvas,
+		view:     view,
+		chResult: chResult,
+	}
+	return <-chResult
+}
+
+// Lists everyone connected to a canvas, across all API instances
+func GetPresence(canvasId string) []Presence {
+	return rm.GetPresence(canvasId)
+}
+
+func (rm *RoomsManager) GetPresence(canvasId string) []Presence {
+	chResult := make(chan []Presence, 1)
+	rm.chPresence <- &dataChPresence{
+		canvasId: canvasId,
+		chResult: chResult,
+	}
+	return <-chResult
+}
+
+// Joins the canvas' room, resume is nil for a fresh connection or where a dropped connection left off
+func Join(userUuid string, email string, role string, resume *Resume, lazy bool, canvas *model.Canvas, conn *websocket.Conn, chResume chan *Client) {
+	rm.Join(userUuid, email, role, resume, lazy, canvas, conn, chResume)
+}
+
+func (rm *RoomsManager) Join(userUuid string, email string, role string, resume *Resume, lazy bool, canvas *model.Canvas, conn *websocket.Conn, chResume chan *Client) {
 	rm.chJoin <- &dataChJoin{
 		userUuid: userUuid,
+		email:    email,
+		role:     role,
+		resume:   resume,
+		lazy:     lazy,
 		canvas:   canvas,
 		conn:     conn,
 		chResume: chResume,
 	}
 }
 
+// Removes the client from its room, only the first call does anything
 func (c *Client) Leave() {
-	rm.chLeave <- c
+	c.leave.Do(func() {
+		c.room.manager.chLeave <- c
+	})
 }
 
-func (room *Room) updateCanvas(ctx *gin.Context, msgIncoming RoomMessage) {
-	canvasData := room.Canvas.CanvasData
+func unmarshalPiece(data map[string]any) (*canvas_service.PieceData, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal piece data: %w", err)
+	}
+
+	var piece canvas_service.PieceData
+	err = json.Unmarshal(bytes, &piece)
+	if err != nil {
+		return nil, fmt.Errorf("piece data is invalid: %w", err)
+	}
+
+	return &piece, nil
+}
+
+func unmarshalLayer(data map[string]any) (*canvas_service.Layer, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal layer data: %w", err)
+	}
+
+	var layer canvas_service.Layer
+	err = json.Unmarshal(bytes, &layer)
+	if err != nil {
+		return nil, fmt.Errorf("layer data is invalid: %w", err)
+	}
+	if layer.ID == "" {
+		return nil, errors.New("layer data is missing an id")
+	}
+
+	return &layer, nil
+}
+
+// The rectangle a query-viewport request asks for, in canvas coordinates
+type viewRequest struct {
+	Left   *float64 `json:"left"`
+	Top    *float64 `json:"top"`
+	Right  *float64 `json:"right"`
+	Bottom *float64 `json:"bottom"`
+}
+
+func unmarshalView(data map[string]any) (canvas_service.Bounds, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return canvas_service.Bounds{}, fmt.Errorf("could not marshal view: %w", err)
+	}
+
+	var view viewRequest
+	err = json.Unmarshal(bytes, &view)
+	if err != nil {
+		return canvas_service.Bounds{}, fmt.Errorf("view is invalid: %w", err)
+	}
+	if view.Left == nil || view.Top == nil || view.Right == nil || view.Bottom == nil {
+		return canvas_service.Bounds{}, errors.New("view needs a left, top, right and bottom")
+	}
+
+	bounds := canvas_service.Bounds{Left: *view.Left, Top: *view.Top, Right: *view.Right, Bottom: *view.Bottom}
+	return bounds, bounds.ValidateView()
+}
+
+// Accepts a mutating event from a client and applies it to the room's canvas, returns the numbered message that should be broadcast to the other clients
+func (room *Room) updateCanvas(userUuid string, msgIncoming RoomMessage) (RoomMessage, error) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	data := msgIncoming.Data
+	pm := room.Canvas.CanvasData.PiecesManager
+	if pm == nil {
+		return RoomMessage{}, fmt.Errorf("%s -- canvas has no pieces manager", msgIncoming.Event)
+	}
 
 	if msgIncoming.Event == "add-piece" {
-		bytes, err := json.Marshal(msgIncoming.Data)
+		piece, err := unmarshalPiece(msgIncoming.Data)
 		if err != nil {
-			logging.LogError("WebSocket", "add-piece -- Could not marhsal piece data", err)
-			return
+			return RoomMessage{}, fmt.Errorf("add-piece -- %w", err)
+		}
+		err = piece.Validate()
+		if err != nil {
+			return RoomMessage{}, fmt.Errorf("add-piece -- %w", err)
 		}
+		if len(pm.Pieces) >= config.CanvasMaxPieces() {
+			return RoomMessage{}, fmt.Errorf("add-piece -- %w, it can have at most %d", canvas_service.ErrTooManyPieces, config.CanvasMaxPieces())
+		}
+		piece.Simplify(config.CanvasPathTolerance())
+		piece.Normalize()
 
-		var piece canvas_service.PieceData
-		err = json.Unmarshal(bytes, &piece)
+		// Pieces are always given a fresh ID by the server, never trust the client with this
+		piece.ID, err = canvas_service.NewPieceID()
 		if err != nil {
-			logging.LogError("WebSocket", "add-piece -- Piece data is invalid", err)
-			return
+			return RoomMessage{}, fmt.Errorf("add-piece -- could not generate piece id: %w", err)
 		}
-		canvasData.PiecesManager.Pieces = append(canvasData.PiecesManager.Pieces, &piece)
+
+		// Clients which don't know about layers draw on the bottom layer
+		if piece.LayerID == "" && len(pm.Layers) > 0 {
+			piece.LayerID = pm.Layers[0].ID
+		}
+		err = pm.CheckLayerEditable(piece.LayerID)
+		if err != nil {
+			return RoomMessage{}, fmt.Errorf("add-piece -- %w", err)
+		}
+
+		data = service.ToMapStringAny(piece)
 
 	} else if msgIncoming.Event == "update-piece" {
-		bytes, err := json.Marshal(msgIncoming.Data)
+		piece, err := unmarshalPiece(msgIncoming.Data)
 		if err != nil {
-			logging.LogError("WebSocket", "update-piece -- Could not marhsal piece data", err)
-			return
+			return RoomMessage{}, fmt.Errorf("update-piece -- %w", err)
 		}
+		err = piece.Validate()
+		if err != nil {
+			return RoomMessage{}, fmt.Errorf("update-piece -- %w", err)
+		}
+		piece.Normalize()
 
-		var piece canvas_service.PieceData
-		err = json.Unmarshal(bytes, &piece)
+		current := room.findPiece(piece.ID)
+		if current == nil {
+			return RoomMessage{}, fmt.Errorf("update-piece -- piece with id %q not found", piece.ID)
+		}
+		err = pm.CheckLayerEditable(current.LayerID)
 		if err != nil {
-			logging.LogError("WebSocket", "update-piece -- Piece data is invalid", err)
-			return
+			return RoomMessage{}, fmt.Errorf("update-piece -- %w", err)
 		}
 
-		if indexFloat, ok := (msgIncoming.Data)["index"].(float64); ok {
-			index := int(indexFloat)
-			if index >= 0 && index < len(canvasData.PiecesManager.Pieces) {
-				canvasData.PiecesManager.Pieces[int(indexFloat)] = &piece
-			}
-		} else {
-			logging.LogError("WebSocket", "update-piece -- Failed to update piece", msgIncoming.Data)
-			return
+		// Moving a piece to another layer needs that layer to be editable too
+		if piece.LayerID == "" {
+			piece.LayerID = current.LayerID
 		}
+		err = pm.CheckLayerEditable(piece.LayerID)
+		if err != nil {
+			return RoomMessage{}, fmt.Errorf("update-piece -- %w", err)
+		}
+
+		data = service.ToMapStringAny(piece)
 
 	} else if msgIncoming.Event == "remove-piece" {
-		if indexFloat, ok := (msgIncoming.Data)["index"].(float64); ok {
-			index := int(indexFloat)
-			if index >= 0 && index < len(canvasData.PiecesManager.Pieces) {
-				canvasData.PiecesManager.Pieces = slices.Delete(canvasData.PiecesManager.Pieces, index, index+1)
-			}
-		} else {
-			logging.LogError("WebSocket", "remove-piece -- Failed to remove piece", msgIncoming.Data)
-			return
+		id, _ := data["id"].(string)
+		current := room.findPiece(id)
+		if current == nil {
+			return RoomMessage{}, fmt.Errorf("remove-piece -- piece with id %q not found", id)
+		}
+		err := pm.CheckLayerEditable(current.LayerID)
+		if err != nil {
+			return RoomMessage{}, fmt.Errorf("remove-piece -- %w", err)
+		}
+
+	} else if msgIncoming.Event == "add-layer" {
+		id, err := canvas_service.NewLayerID()
+		if err != nil {
+			return RoomMessage{}, fmt.Errorf("add-layer -- could not generate layer id: %w", err)
+		}
+
+		name, _ := data["name"].(string)
+		if name == "" {
+			name = fmt.Sprintf("Layer %d", len(pm.Layers)+1)
 		}
+
+		// New layers go on top
+		data = service.ToMapStringAny(canvas_service.Layer{ID: id, Name: name})
+
+	} else if msgIncoming.Event == "update-layer" {
+		id, _ := data["id"].(string)
+		current := pm.Layer(id)
+		if current == nil {
+			return RoomMessage{}, fmt.Errorf("update-layer -- %w: %q", canvas_service.ErrLayerNotFound, id)
+		}
+
+		// Only the given fields change, the whole layer is broadcast so every room ends up with the same one
+		layer := *current
+		if name, ok := data["name"].(string); ok && name != "" {
+			layer.Name = name
+		}
+		if hidden, ok := data["hidden"].(bool); ok {
+			layer.Hidden = hidden
+		}
+		if locked, ok := data["locked"].(bool); ok {
+			layer.Locked = locked
+		}
+
+		data = service.ToMapStringAny(layer)
+
 	} else if msgIncoming.Event == "update-canvas-data" {
 		bytes, err := json.Marshal(msgIncoming.Data["canvas_data"])
 		if err != nil {
-			logging.LogError("WebSocket", "update-canvas-data -- Could not marhsal piece data", err)
-			return
+			return RoomMessage{}, fmt.Errorf("update-canvas-data -- could not marshal canvas data: %w", err)
 		}
 
 		var incomingCanvasData canvas_service.CanvasData
 		err = json.Unmarshal(bytes, &incomingCanvasData)
 		if err != nil {
-			logging.LogError("WebSocket", "update-canvas-data -- Piece data is invalid", err)
-			return
+			return RoomMessage{}, fmt.Errorf("update-canvas-data -- canvas data is invalid: %w", err)
+		}
+		err = canvas_service.ValidateColor(incomingCanvasData.BackgroundColor)
+		if err != nil {
+			return RoomMessage{}, fmt.Errorf("update-canvas-data -- %w", err)
 		}
 
-		canvasData.BackgroundColor = incomingCanvasData.BackgroundColor
+		canvasData := room.Canvas.CanvasData
+		canvasData.BackgroundColor = canvas_service.NormalizeColor(incomingCanvasData.BackgroundColor)
 
-		if auth_service.Auth(ctx) == room.Canvas.UserId {
+		if userUuid == room.Canvas.UserId {
 			// Only canvas owner allowed:
 			canvasData.Name = incomingCanvasData.Name
 		}
+
+		data = map[string]any{
+			"canvas_data": service.ToMapStringAny(canvasData),
+		}
+	}
+
+	// Remember piece changes so the user can undo them later
+	var before *canvas_service.PieceData
+	index := -1
+	if msgIncoming.Event == "update-piece" || msgIncoming.Event == "remove-piece" {
+		id, _ := data["id"].(string)
+		before = room.findPiece(id)
+		index = pm.IndexOf(id)
+	}
+
+	err := room.applyEvent(msgIncoming.Event, data)
+	if err != nil {
+		return RoomMessage{}, err
+	}
+
+	switch msgIncoming.Event {
+	case "add-piece", "update-piece":
+		id, _ := data["id"].(string)
+		room.record(userUuid, operation{before: before, after: room.findPiece(id), index: pm.IndexOf(id)})
+	case "remove-piece":
+		room.record(userUuid, operation{before: before, index: index})
+	}
+
+	msgIncoming.Data = data
+	return room.stamp(msgIncoming), nil
+}
+
+// Applies an already accepted mutating event to the room's canvas, callers must hold room.mu
+func (room *Room) applyEvent(event string, data map[string]any) error {
+	canvasData := room.Canvas.CanvasData
+
+	if event == "add-piece" {
+		piece, err := unmarshalPiece(data)
+		if err != nil {
+			return fmt.Errorf("add-piece -- %w", err)
+		}
+		// New pieces go on top, pieces added back by undo or redo go back where they were in the drawing order
+		pieces := canvasData.PiecesManager.Pieces
+		index := len(pieces)
+		switch at := data["index"].(type) {
+		case int:
+			index = min(max(at, 0), len(pieces))
+		case float64:
+			index = min(max(int(at), 0), len(pieces))
+		}
+		canvasData.PiecesManager.Pieces = slices.Insert(pieces, index, piece)
+		if room.index != nil {
+			room.index.Insert(piece)
+		}
+
+	} else if event == "update-piece" {
+		piece, err := unmarshalPiece(data)
+		if err != nil {
+			return fmt.Errorf("update-piece -- %w", err)
+		}
+
+		index := canvasData.PiecesManager.IndexOf(piece.ID)
+		if index < 0 {
+			return fmt.Errorf("update-piece -- piece with id %q not found", piece.ID)
+		}
+		canvasData.PiecesManager.Pieces[index] = piece
+		if room.index != nil {
+			room.index.Insert(piece)
+		}
+
+	} else if event == "remove-piece" {
+		id, _ := data["id"].(string)
+		index := canvasData.PiecesManager.IndexOf(id)
+		if index < 0 {
+			return fmt.Errorf("remove-piece -- piece with id %q not found", id)
+		}
+		canvasData.PiecesManager.Pieces = slices.Delete(canvasData.PiecesManager.Pieces, index, index+1)
+		if room.index != nil {
+			room.index.Remove(id)
+		}
+
+	} else if event == "update-canvas-data" {
+		bytes, err := json.Marshal(data["canvas_data"])
+		if err != nil {
+			return fmt.Errorf("update-canvas-data -- could not marshal canvas data: %w", err)
+		}
+
+		var incomingCanvasData canvas_service.CanvasData
+		err = json.Unmarshal(bytes, &incomingCanvasData)
+		if err != nil {
+			return fmt.Errorf("update-canvas-data -- canvas data is invalid: %w", err)
+		}
+
+		canvasData.BackgroundColor = incomingCanvasData.BackgroundColor
+		canvasData.Name = incomingCanvasData.Name
+
+	} else if event == "add-layer" {
+		layer, err := unmarshalLayer(data)
+		if err != nil {
+			return fmt.Errorf("add-layer -- %w", err)
+		}
+		if canvasData.PiecesManager.Layer(layer.ID) != nil {
+			return fmt.Errorf("add-layer -- layer with id %q already exists", layer.ID)
+		}
+		canvasData.PiecesManager.Layers = append(canvasData.PiecesManager.Layers, layer)
+
+	} else if event == "update-layer" {
+		layer, err := unmarshalLayer(data)
+		if err != nil {
+			return fmt.Errorf("update-layer -- %w", err)
+		}
+		index := slices.IndexFunc(canvasData.PiecesManager.Layers, func(l *canvas_service.Layer) bool { return l.ID == layer.ID })
+		if index < 0 {
+			return fmt.Errorf("update-layer -- %w: %q", canvas_service.ErrLayerNotFound, layer.ID)
+		}
+		canvasData.PiecesManager.Layers[index] = layer
+
+	} else if event == "reorder-layers" {
+		rawIds, _ := data["ids"].([]any)
+		ids := make([]string, 0, len(rawIds))
+		for _, rawId := range rawIds {
+			id, _ := rawId.(string)
+			ids = append(ids, id)
+		}
+		err := canvasData.PiecesManager.ReorderLayers(ids)
+		if err != nil {
+			return fmt.Errorf("reorder-layers -- %w", err)
+		}
+	}
+
+	if event == "add-piece" || event == "update-piece" || event == "remove-piece" {
+		canvasData.PiecesManager.SetBounds()
 	}
 
 	room.Canvas.CanvasData = canvasData
+	room.applied++
+
+	return nil
+}
+
+// Returns a copy of the room's canvas data which is safe to read outside of the room
+func (room *Room) GetCanvasData() canvas_service.CanvasData {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	return room.copyCanvasData()
 }
 
-func (c *Client) Reader(ctx *gin.Context) {
+// Same as GetCanvasData, callers must hold room.mu
+func (room *Room) copyCanvasData() canvas_service.CanvasData {
+	canvasData := room.Canvas.CanvasData
+	if canvasData.PiecesManager != nil {
+		piecesManager := *canvasData.PiecesManager
+		piecesManager.Pieces = slices.Clone(piecesManager.Pieces)
+		piecesManager.Layers = slices.Clone(piecesManager.Layers)
+		canvasData.PiecesManager = &piecesManager
+	}
+
+	return canvasData
+}
+
+// The pieces intersecting the view in drawing order, safe to read outside of the room as pieces are replaced rather than changed
+func (room *Room) PiecesIn(view canvas_service.Bounds) []*canvas_service.PieceData {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	pm := room.Canvas.CanvasData.PiecesManager
+	if room.index == nil {
+		room.index = pm.SpatialIndex()
+	}
+	return pm.PiecesIn(room.index, view)
+}
+
+func (c *Client) getRole() string {
+	c.room.mu.Lock()
+	defer c.room.mu.Unlock()
+
+	return c.role
+}
+
+func (c *Client) Reader() {
 	logging.LogDebug("WebSocket", "Reader -- Starting reader for client", nil)
+	// Connections which drop without a close frame only show up as a read error
+	defer c.Leave()
 
 	for {
 		msgIncoming := RoomMessage{}
@@ -339,26 +1246,102 @@ func (c *Client) Reader(ctx *gin.Context) {
 			Data:   msgIncoming.Data,
 		}
 
-		shouldUpdateCanvas := imissphp.InArray(msgIncoming.Event, []string{
-			"add-piece",
-			"update-piece",
-			"remove-piece",
-			"update-canvas-data",
-		})
+		shouldUpdateCanvas := imissphp.InArray(msgIncoming.Event, mutatingEvents)
 
-		if shouldUpdateCanvas {
-			c.room.updateCanvas(ctx, msgIncoming)
+		if imissphp.InArray(msgIncoming.Event, serverEvents) {
+			logging.LogInfo("WebSocket", "Dropping server only event from client", msgIncoming.Event)
+			continue
+		}
+
+		if msgIncoming.Event == "query-viewport" {
+			// Answered to the sender only, lets clients load huge canvases a viewport at a time
+			view, err := unmarshalView(msgIncoming.Data)
+			if err != nil {
+				c.reject(msgIncoming, err)
+				continue
+			}
+			c.Send(RoomMessage{
+				Event: "viewport-pieces",
+				Data: map[string]any{
+					"request_id": msgIncoming.Data["request_id"],
+					"pieces":     c.room.PiecesIn(view),
+				},
+			})
+			continue
+		}
+
+		if imissphp.InArray(msgIncoming.Event, ephemeralEvents) {
+			if !c.allowEphemeral(msgIncoming.Event) {
+				continue // Throttled, the client will send a newer position soon enough
+			}
 
-			if msgIncoming.Event == "update-canvas-data" {
-				canvasDataMap := service.ToMapStringAny(c.room.Canvas.CanvasData)
-				msgToBroadcast.Data = map[string]any{
-					"canvas_data": canvasDataMap,
+			// Identify the sender from the connection, never from what the client claims
+			data := c.presence().toMap()
+			for k, v := range msgIncoming.Data {
+				if _, reserved := data[k]; !reserved {
+					data[k] = v
 				}
 			}
+			msgToBroadcast.Email = c.email
+			msgToBroadcast.Data = data
+		}
+
+		if imissphp.InArray(msgIncoming.Event, historyEvents) {
+			if c.getRole() == model.CanvasRoleViewer {
+				logging.LogInfo("WebSocket", "Dropping history event from viewer", msgIncoming.Event)
+				c.reject(msgIncoming, ErrViewerCanNotChange)
+				continue
+			}
+
+			var msgApplied RoomMessage
+			if msgIncoming.Event == "undo" {
+				msgApplied, err = c.room.undo(c.userUuid, c.email)
+			} else {
+				msgApplied, err = c.room.redo(c.userUuid, c.email)
+			}
+			if errors.Is(err, ErrNothingToUndo) || errors.Is(err, ErrNothingToRedo) {
+				c.Send(RoomMessage{Event: "history-empty", Data: map[string]any{"event": msgIncoming.Event}})
+				continue
+			}
+			if err != nil {
+				logging.LogError("WebSocket", "Failed to "+msgIncoming.Event, err)
+				c.reject(msgIncoming, err)
+				continue
+			}
+
+			// Everyone gets the resulting change, including the author who has no way of working it out themselves
+			c.room.manager.Broadcast(msgApplied)
+			continue
+		}
+
+		if shouldUpdateCanvas && c.getRole() == model.CanvasRoleViewer {
+			logging.LogInfo("WebSocket", "Dropping mutating event from viewer", msgIncoming.Event)
+			c.reject(msgIncoming, ErrViewerCanNotChange)
+			continue
+		}
+
+		if shouldUpdateCanvas {
+			msgToBroadcast, err = c.room.updateCanvas(c.userUuid, msgToBroadcast)
+			if err != nil {
+				logging.LogError("WebSocket", "Failed to update canvas", err)
+				c.reject(msgIncoming, err)
+				continue
+			}
+
+			if msgIncoming.Event == "add-piece" {
+				// Let the author know which ID the server assigned to their piece
+				c.Send(RoomMessage{
+					Event: "add-piece-ack",
+					Data: map[string]any{
+						"client_ref": msgIncoming.Data["client_ref"],
+						"id":         msgToBroadcast.Data["id"],
+					},
+				})
+			}
 		}
 
 		// Fwd msg to other clients in the room
-		Broadcast(msgToBroadcast)
+		c.room.manager.Broadcast(msgToBroadcast)
 	}
 }
 
@@ -376,6 +1359,18 @@ func (c *Client) Send(msg RoomMessage) {
 	c.chSend <- msg
 }
 
+// Tells the client one of its messages was rejected, nothing was broadcast for it
+func (c *Client) reject(msgIncoming RoomMessage, err error) {
+	c.Send(RoomMessage{
+		Event: "error",
+		Data: map[string]any{
+			"event":      msgIncoming.Event,
+			"message":    err.Error(),
+			"client_ref": msgIncoming.Data["client_ref"],
+		},
+	})
+}
+
 func (c *Client) GetRoom() *Room {
 	return c.room
 }
@@ -386,7 +1381,11 @@ func Connect(c *gin.Context) *websocket.Conn {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		logging.LogError("Connect", "Failed upgrading a connection", err)
+		return conn
 	}
 
+	// Larger messages close the connection, a whole canvas is the most a client ever needs to send at once
+	conn.SetReadLimit(config.CanvasMaxBytes())
+
 	return conn
 }